@@ -1,65 +1,105 @@
 package examples
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"sync"
 	"testing"
+	"testing/synctest"
 	"time"
 )
 
 func TestOrDoneBetweenGoroutines(t *testing.T) {
-	var wg sync.WaitGroup
-	done := make(chan any)
+	synctest.Test(t, func(t *testing.T) {
+		var wg sync.WaitGroup
+		done := make(chan any)
+		// 日志写入 buf 而不是标准错误，结束后检查记录；JSONHandler 会串行化并发的写入
+		var buf bytes.Buffer
+		log := slog.New(slog.NewJSONHandler(&buf, nil))
 
-	cows := make(chan any, 100)
-	pigs := make(chan any, 100)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case cows <- "moo":
+		cows := make(chan any, 100)
+		pigs := make(chan any, 100)
+		// 每 1ms 产出一项；在 synctest 气泡中 time.Sleep 使用虚拟时间
+		produce := func(c chan<- any, v any) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				case c <- v:
+				}
+				time.Sleep(1 * time.Millisecond)
 			}
 		}
-	}()
+		wg.Add(2)
+		go produce(cows, "moo")
+		go produce(pigs, "oink")
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case pigs <- "oink":
+		var gotCows, gotPigs []any
+		wg.Add(1)
+		go consumeCows(&wg, log, done, cows, &gotCows)
+		wg.Add(1)
+		go consumePigs(&wg, log, done, pigs, &gotPigs)
+
+		// 0ms, 1ms, ..., 10ms 共产出 11 项，避开与生产者在同一时刻被唤醒
+		time.Sleep(10*time.Millisecond + 500*time.Microsecond)
+		synctest.Wait()
+		close(done)
+		log.Info("close done")
+		wg.Wait()
+
+		if len(gotCows) != 11 || len(gotPigs) != 11 {
+			t.Fatalf("got %d cows and %d pigs, want 11 each", len(gotCows), len(gotPigs))
+		}
+		for _, cow := range gotCows {
+			if cow != "moo" {
+				t.Fatalf("got cow %v, want moo", cow)
+			}
+		}
+		for _, pig := range gotPigs {
+			if pig != "oink" {
+				t.Fatalf("got pig %v, want oink", pig)
 			}
 		}
-	}()
-
-	wg.Add(1)
-	go consumeCows(&wg, done, cows)
-	wg.Add(1)
-	go consumePigs(&wg, done, pigs)
 
-	time.Sleep(1 * time.Millisecond)
-	close(done)
-	fmt.Println("close done")
-	wg.Wait()
+		quit := make(map[string]float64)
+		var msgs []string
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte{'\n'}) {
+			var rec map[string]any
+			if err := json.Unmarshal(line, &rec); err != nil {
+				t.Fatal(err)
+			}
+			msgs = append(msgs, rec[slog.MessageKey].(string))
+			if rec[slog.MessageKey] == "quit consumer" {
+				quit[rec["consumer"].(string)] = rec["items"].(float64)
+			}
+		}
+		if len(msgs) != 3 || msgs[0] != "close done" {
+			t.Fatalf("got records %v, want close done followed by two quit consumer", msgs)
+		}
+		if quit["cows"] != 11 || quit["pigs"] != 11 {
+			t.Fatalf("got quit records %v, want 11 items for cows and pigs", quit)
+		}
+	})
 }
 
-func consumeCows(wg *sync.WaitGroup, done <-chan any, cows <-chan any) {
+func consumeCows(wg *sync.WaitGroup, log *slog.Logger, done <-chan any, cows <-chan any, got *[]any) {
 	defer wg.Done()
 	for cow := range orDone(done, cows) {
 		// do something
-		fmt.Println(cow)
+		*got = append(*got, cow)
 	}
-	fmt.Println("quit consumeCows")
+	log.Info("quit consumer", "consumer", "cows", "items", len(*got))
 }
 
-func consumePigs(wg *sync.WaitGroup, done <-chan any, pigs <-chan any) {
+func consumePigs(wg *sync.WaitGroup, log *slog.Logger, done <-chan any, pigs <-chan any, got *[]any) {
 	defer wg.Done()
 	for pig := range orDone(done, pigs) {
 		// do something
-		fmt.Println(pig)
+		*got = append(*got, pig)
 	}
-	fmt.Println("quit consumePigs")
+	log.Info("quit consumer", "consumer", "pigs", "items", len(*got))
 }
 
 func orDone(done <-chan any, c <-chan any) <-chan any {
@@ -1,11 +1,13 @@
 package examples
 
 import (
-	"fmt"
+	"math/big"
 	"runtime"
 	"sync"
 	"testing"
-	"time"
+	"testing/synctest"
+
+	"example/concurrency/pipeline/prime"
 )
 
 // 重复一个 fn 并持续发送数据到 chan stream
@@ -69,12 +71,7 @@ func fanInWithDone[T any](done <-chan int, chans ...<-chan T) <-chan T {
 // fn 测试，取素数
 func primeFinderWithDone(done <-chan int, randIntStream <-chan int) <-chan int {
 	isPrime := func(randomInt int) bool {
-		for i := randomInt - 1; i > 1; i-- {
-			if randomInt%i == 0 {
-				return false
-			}
-		}
-		return true
+		return randomInt >= 0 && prime.IsPrime(uint64(randomInt))
 	}
 	primes := make(chan int)
 	go func() {
@@ -85,7 +82,12 @@ func primeFinderWithDone(done <-chan int, randIntStream <-chan int) <-chan int {
 				return
 			case randomInt := <-randIntStream:
 				if isPrime(randomInt) {
-					primes <- randomInt
+					// 发送时同样监听 done，否则下游退出后该例程会阻塞泄漏
+					select {
+					case <-done:
+						return
+					case primes <- randomInt:
+					}
 				}
 			}
 		}
@@ -94,37 +96,47 @@ func primeFinderWithDone(done <-chan int, randIntStream <-chan int) <-chan int {
 }
 
 func TestFanInOutWithDone(t *testing.T) {
-	done := make(chan int)
-	defer close(done)
-	numFetcher := func(start int) func() int {
-		return func() int {
-			rt := start
-			start++
-			return rt
+	synctest.Test(t, func(t *testing.T) {
+		const from, want = 10000000, 500
+		done := make(chan int)
+		numFetcher := func(start int) func() int {
+			return func() int {
+				rt := start
+				start++
+				return rt
+			}
 		}
-	}
-	start := time.Now()
-	intStream := repeatFuncWithDone(done, numFetcher(10000000))
+		intStream := repeatFuncWithDone(done, numFetcher(from))
 
-	// ! native；单例程
-	// primeStream := primeFinder(done, intStream)
-	// for rando := range take(done, primeStream, 50) {
-	// 	fmt.Println(rando)
-	// }
+		// ! native；单例程
+		// primeStream := primeFinder(done, intStream)
+		// for rando := range take(done, primeStream, 50) {
+		// 	fmt.Println(rando)
+		// }
 
-	// ! mul goroutines 多例程并行
-	// fan out
-	CPUCount := runtime.NumCPU()
-	count := CPUCount
-	primeFinderChans := make([]<-chan int, count)
-	for i := 0; i < count; i++ {
-		primeFinderChans[i] = primeFinderWithDone(done, intStream)
-	}
-	// fan in
-	fannedInStream := fanInWithDone(done, primeFinderChans...)
-	for rando := range takeWithDone(done, fannedInStream, 500) {
-		fmt.Println(rando)
-	}
+		// ! mul goroutines 多例程并行
+		// fan out
+		CPUCount := runtime.NumCPU()
+		count := CPUCount
+		primeFinderChans := make([]<-chan int, count)
+		for i := 0; i < count; i++ {
+			primeFinderChans[i] = primeFinderWithDone(done, intStream)
+		}
+		// fan in
+		fannedInStream := fanInWithDone(done, primeFinderChans...)
+		seen := make(map[int]bool)
+		for rando := range takeWithDone(done, fannedInStream, want) {
+			if rando < from || !big.NewInt(int64(rando)).ProbablyPrime(0) || seen[rando] {
+				t.Fatalf("got %d, want a distinct prime >= %d", rando, from)
+			}
+			seen[rando] = true
+		}
+		if len(seen) != want {
+			t.Fatalf("got %d primes, want %d", len(seen), want)
+		}
 
-	fmt.Print(time.Since(start))
+		// 关闭 done 后所有阶段都应退出；残留的阻塞例程会使 synctest.Test 报告死锁
+		close(done)
+		synctest.Wait()
+	})
 }
@@ -2,10 +2,14 @@ package examples
 
 import (
 	"fmt"
-	"log"
+	"math/big"
 	"runtime"
+	"slices"
 	"sync"
 	"testing"
+	"testing/synctest"
+
+	"example/concurrency/pipeline/prime"
 )
 
 // // 重复一个 fn 并持续发送数据到 chan stream
@@ -91,13 +95,10 @@ func withoutDone_generate(start int, n int) <-chan int {
 
 // 素数查找
 func primeFinder(in <-chan int) <-chan int {
+	// ! 原先从 num-1 向下逐个试除，400000000 附近每个数都要试除上亿次，且把 0 与 1 误判为素数；
+	// 改用确定性的 Miller-Rabin 测试
 	isPrime := func(num int) bool {
-		for i := num - 1; i > 1; i-- {
-			if num%i == 0 {
-				return false
-			}
-		}
-		return true
+		return num >= 0 && prime.IsPrime(uint64(num))
 	}
 	primes := make(chan int)
 	go func() {
@@ -118,16 +119,36 @@ func primeFinder(in <-chan int) <-chan int {
 }
 
 func TestFanInOut(t *testing.T) {
-	intStream := withoutDone_generate(400000000, 200)
-	// fan out
-	fanOutChs, err := fanOut(16, intStream, primeFinder)
-	if err != nil {
-		log.Fatal(err)
-	}
-	// fan in
-	outStream := fanIn(fanOutChs...)
+	synctest.Test(t, func(t *testing.T) {
+		const start, n = 400000000, 200
+		intStream := withoutDone_generate(start, n)
+		// fan out
+		fanOutChs, err := fanOut(16, intStream, primeFinder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// fan in
+		outStream := fanIn(fanOutChs...)
 
-	for r := range outStream {
-		fmt.Println(r)
+		var got []int
+		for r := range outStream {
+			got = append(got, r)
+		}
+		// fan in 的输出顺序取决于各 worker 的完成次序，排序后再比较
+		slices.Sort(got)
+		if want := referencePrimes(start, n); !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+// referencePrimes 返回 [start, start+n) 内的素数；ProbablyPrime 对 2^64 以内的数是确定性的
+func referencePrimes(start, n int) []int {
+	var primes []int
+	for i := start; i < start+n; i++ {
+		if big.NewInt(int64(i)).ProbablyPrime(0) {
+			primes = append(primes, i)
+		}
 	}
+	return primes
 }
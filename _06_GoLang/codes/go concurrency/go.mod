@@ -1,3 +1,5 @@
 module example/concurrency
 
-go 1.22.3
+go 1.25
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,10 @@
+package examples
+
+import (
+	"testing"
+
+	"example/concurrency/pipeline/leakcheck"
+)
+
+// 所有并发示例测试结束后检查残留的例程
+func TestMain(m *testing.M) { leakcheck.VerifyTestMain(m) }
@@ -0,0 +1,281 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBreakerOpen 表示熔断器处于打开状态 (或半开状态的试探名额已用完)，请求被拒绝
+var ErrBreakerOpen = errors.New("pipeline: circuit breaker is open")
+
+// BreakerState 是熔断器的状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常放行并统计失败
+	BreakerOpen                         // 拒绝所有请求，冷却结束后进入半开
+	BreakerHalfOpen                     // 放行少量试探请求，全部成功则闭合，任一失败则重新打开
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerConfig 描述熔断器的跳闸条件与冷却时间
+type BreakerConfig struct {
+	ConsecutiveFailures int                         // 连续失败达到该值时跳闸，0 表示不使用该条件
+	FailureRatio        float64                     // 失败比例达到该值时跳闸，0 表示不使用该条件
+	MinRequests         int                         // 计算失败比例所需的最少请求数
+	Interval            time.Duration               // 闭合状态下清零统计的周期，0 表示只在状态变化时清零
+	CoolDown            time.Duration               // 打开状态持续多久后进入半开
+	HalfOpenRequests    int                         // 半开状态允许的试探请求数，默认为 1
+	OnStateChange       func(from, to BreakerState) // 状态变化回调，在持有熔断器内部锁时调用，不能再调用 Breaker 的方法
+}
+
+// BreakerMetrics 是熔断器的累计计数
+type BreakerMetrics struct {
+	State     BreakerState
+	Requests  int64 // 被放行的请求数
+	Successes int64
+	Failures  int64
+	Rejected  int64 // 被拒绝的请求数
+	Trips     int64 // 跳闸 (进入打开状态) 次数
+}
+
+// Breaker 是熔断器：下游持续失败时快速拒绝请求，避免所有 worker 持续冲击已经故障的依赖
+type Breaker struct {
+	conf BreakerConfig
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64    // 每次状态变化或统计清零时递增，旧一代请求的结果被忽略
+	expiry      time.Time // 打开状态的冷却结束时间，或闭合状态的统计清零时间
+	requests    int
+	successes   int
+	failures    int
+	consecutive int
+	inflight    int // 半开状态下已放行的试探请求数
+	metrics     BreakerMetrics
+}
+
+// NewBreaker 返回处于闭合状态的熔断器
+func NewBreaker(conf BreakerConfig) *Breaker {
+	conf.HalfOpenRequests = max(conf.HalfOpenRequests, 1)
+	b := &Breaker{conf: conf}
+	b.reset(time.Now())
+	return b
+}
+
+// State 返回熔断器的当前状态
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	return b.state
+}
+
+// Metrics 返回熔断器的累计计数
+func (b *Breaker) Metrics() BreakerMetrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	m := b.metrics
+	m.State = b.state
+	return m
+}
+
+// Do 在熔断器允许时调用 fn 并记录其结果；被拒绝时返回 ErrBreakerOpen。
+// fn 发生 panic 时记为失败，panic 继续向上传播
+func (b *Breaker) Do(fn func() error) error {
+	gen, err := b.allow()
+	if err != nil {
+		return err
+	}
+	ok := false
+	defer func() { b.record(gen, ok) }()
+	err = fn()
+	ok = err == nil
+	return err
+}
+
+// allow 判断是否放行一个请求，返回请求所属的代
+func (b *Breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	switch {
+	case b.state == BreakerOpen,
+		b.state == BreakerHalfOpen && b.inflight >= b.conf.HalfOpenRequests:
+		b.metrics.Rejected++
+		return 0, ErrBreakerOpen
+	case b.state == BreakerHalfOpen:
+		b.inflight++
+	}
+	b.requests++
+	b.metrics.Requests++
+	return b.generation, nil
+}
+
+// record 记录 gen 代请求的结果
+func (b *Breaker) record(gen uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.advance(now)
+	if ok {
+		b.metrics.Successes++
+	} else {
+		b.metrics.Failures++
+	}
+	if gen != b.generation || b.state == BreakerOpen {
+		return
+	}
+	switch {
+	case ok:
+		b.successes++
+		b.consecutive = 0
+		if b.state == BreakerHalfOpen && b.successes >= b.conf.HalfOpenRequests {
+			b.setState(BreakerClosed, now)
+		}
+	case b.state == BreakerHalfOpen:
+		b.setState(BreakerOpen, now)
+	default:
+		b.failures++
+		b.consecutive++
+		if b.tripped() {
+			b.setState(BreakerOpen, now)
+		}
+	}
+}
+
+// tripped 判断闭合状态下的统计是否满足跳闸条件
+func (b *Breaker) tripped() bool {
+	c := b.conf
+	if c.ConsecutiveFailures > 0 && b.consecutive >= c.ConsecutiveFailures {
+		return true
+	}
+	return c.FailureRatio > 0 && b.requests >= max(c.MinRequests, 1) &&
+		float64(b.failures)/float64(b.requests) >= c.FailureRatio
+}
+
+// advance 处理随时间发生的状态变化：冷却结束进入半开，或闭合状态的统计周期到期
+func (b *Breaker) advance(now time.Time) {
+	if b.expiry.IsZero() || now.Before(b.expiry) {
+		return
+	}
+	switch b.state {
+	case BreakerOpen:
+		b.setState(BreakerHalfOpen, now)
+	case BreakerClosed:
+		b.reset(now)
+	}
+}
+
+func (b *Breaker) setState(to BreakerState, now time.Time) {
+	from := b.state
+	b.state = to
+	if to == BreakerOpen {
+		b.metrics.Trips++
+	}
+	b.reset(now)
+	if b.conf.OnStateChange != nil {
+		b.conf.OnStateChange(from, to)
+	}
+}
+
+// release 归还 gen 代请求占用的半开试探名额，用于没有结果的请求 (如被过滤掉的输入)
+func (b *Breaker) release(gen uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen == b.generation && b.state == BreakerHalfOpen && b.inflight > 0 {
+		b.inflight--
+	}
+}
+
+// reset 开始新的一代并清零统计
+func (b *Breaker) reset(now time.Time) {
+	b.generation++
+	b.requests, b.successes, b.failures, b.consecutive, b.inflight = 0, 0, 0, 0, 0
+	b.expiry = time.Time{}
+	switch {
+	case b.state == BreakerOpen:
+		b.expiry = now.Add(b.conf.CoolDown)
+	case b.state == BreakerClosed && b.conf.Interval > 0:
+		b.expiry = now.Add(b.conf.Interval)
+	}
+}
+
+// BreakerStage 用熔断器包装逐项处理函数：熔断器打开时不调用 fn，直接返回 ErrBreakerOpen
+func BreakerStage[T, R any](b *Breaker, fn Stage[T, R]) Stage[T, R] {
+	return func(ctx context.Context, v T) (R, error) {
+		var r R
+		err := b.Do(func() (err error) {
+			r, err = fn(ctx, v)
+			return err
+		})
+		return r, err
+	}
+}
+
+// BreakerWork 用熔断器包装 FanOut 的 work 函数：熔断器拒绝的输入不交给 work，而是交给 rejected (可为 nil)。
+// 每个放行的输入单独交给一次 work 调用，直到其输出关闭，因此结果总能对应到放行它的请求：
+// 任一输出被 failed 判为失败记为失败，否则有输出记为成功；没有输出 (如被过滤) 不计成败，只归还试探名额。
+// opts 用于包装后的 work 的日志，每次调用包装后的 work 分配一个 worker 编号 (同 FanOut 中的 worker 顺序)。
+// ! 输入逐个处理，work 内部跨输入的状态 (如批处理) 不会保留
+func BreakerWork[T, R any](b *Breaker, work func(ctx context.Context, in <-chan T) <-chan R,
+	failed func(R) bool, rejected func(T), opts ...Option) func(ctx context.Context, in <-chan T) <-chan R {
+	c := newConfig(opts)
+	var workers atomic.Int64
+	return func(ctx context.Context, in <-chan T) <-chan R {
+		out := make(chan R)
+		l := c.newStageLog("breaker_work", int(workers.Add(1)-1))
+		go func() {
+			defer close(out)
+			defer l.lifecycle(ctx)()
+			for v := range OrDone(ctx, in) {
+				l.received()
+				gen, err := b.allow()
+				if err != nil {
+					if rejected != nil {
+						rejected(v)
+					}
+					continue
+				}
+				one := make(chan T, 1)
+				one <- v
+				close(one)
+				outputs, ok := 0, true
+				results := work(ctx, one)
+				for r := range results {
+					outputs++
+					ok = ok && !failed(r)
+					if Send(ctx, out, r) != nil {
+						for range results {
+						}
+						b.release(gen)
+						return
+					}
+					l.sent()
+				}
+				if outputs == 0 {
+					b.release(gen)
+				} else {
+					b.record(gen, ok)
+				}
+			}
+		}()
+		return out
+	}
+}
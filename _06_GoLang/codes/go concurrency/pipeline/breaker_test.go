@@ -0,0 +1,232 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+var errDown = errors.New("downstream is down")
+
+func fail() error    { return errDown }
+func succeed() error { return nil }
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var changes []string
+		b := NewBreaker(BreakerConfig{
+			ConsecutiveFailures: 3,
+			CoolDown:            time.Second,
+			OnStateChange: func(from, to BreakerState) {
+				changes = append(changes, from.String()+"->"+to.String())
+			},
+		})
+		b.Do(fail)
+		b.Do(fail)
+		b.Do(succeed) // 成功会清零连续失败计数
+		for range 3 {
+			b.Do(fail)
+		}
+		if b.State() != BreakerOpen {
+			t.Fatalf("got state %v, want open", b.State())
+		}
+		called := false
+		if err := b.Do(func() error { called = true; return nil }); !errors.Is(err, ErrBreakerOpen) || called {
+			t.Fatalf("an open breaker should reject without calling fn, got err %v", err)
+		}
+
+		time.Sleep(time.Second)
+		if b.State() != BreakerHalfOpen {
+			t.Fatalf("got state %v after the cool-down, want half-open", b.State())
+		}
+		// 半开状态的试探失败，重新打开
+		b.Do(fail)
+		time.Sleep(time.Second)
+		b.Do(succeed)
+		if b.State() != BreakerClosed {
+			t.Fatalf("got state %v after a successful probe, want closed", b.State())
+		}
+		want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+		if !slices.Equal(changes, want) {
+			t.Fatalf("got state changes %v, want %v", changes, want)
+		}
+		m := b.Metrics()
+		if m.Requests != 8 || m.Successes != 2 || m.Failures != 6 || m.Rejected != 1 || m.Trips != 2 {
+			t.Fatalf("unexpected metrics %+v", m)
+		}
+	})
+}
+
+func TestBreakerFailureRatio(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		b := NewBreaker(BreakerConfig{FailureRatio: 0.5, MinRequests: 4, Interval: time.Minute, CoolDown: time.Second})
+		b.Do(fail)
+		b.Do(fail)
+		b.Do(succeed)
+		// 未达到 MinRequests 前不跳闸
+		if b.State() != BreakerClosed {
+			t.Fatalf("got state %v, want closed", b.State())
+		}
+		// 统计周期到期后清零
+		time.Sleep(time.Minute)
+		b.Do(fail)
+		b.Do(succeed)
+		b.Do(succeed)
+		b.Do(succeed)
+		if b.State() != BreakerClosed {
+			t.Fatalf("got state %v with 1/4 failures, want closed", b.State())
+		}
+		b.Do(fail)
+		b.Do(fail)
+		b.Do(fail)
+		if b.State() != BreakerOpen {
+			t.Fatalf("got state %v with 4/7 failures, want open", b.State())
+		}
+	})
+}
+
+func TestBreakerHalfOpenRequests(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		b := NewBreaker(BreakerConfig{ConsecutiveFailures: 1, CoolDown: time.Second, HalfOpenRequests: 2})
+		b.Do(fail)
+		time.Sleep(time.Second)
+		release := make(chan struct{})
+		probe := func() error {
+			<-release
+			return nil
+		}
+		go b.Do(probe)
+		go b.Do(probe)
+		synctest.Wait()
+		// 试探名额已满
+		if err := b.Do(succeed); !errors.Is(err, ErrBreakerOpen) {
+			t.Fatalf("got err %v, want %v", err, ErrBreakerOpen)
+		}
+		close(release)
+		synctest.Wait()
+		if b.State() != BreakerClosed {
+			t.Fatalf("got state %v after 2 successful probes, want closed", b.State())
+		}
+	})
+}
+
+func TestBreakerDoPanic(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		b := NewBreaker(BreakerConfig{ConsecutiveFailures: 1, CoolDown: time.Second})
+		b.Do(fail)
+		time.Sleep(time.Second)
+		// 半开状态的试探发生 panic，记为失败并重新打开，而不是一直占用试探名额
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("the panic was not propagated")
+				}
+			}()
+			b.Do(func() error { panic("boom") })
+		}()
+		if b.State() != BreakerOpen {
+			t.Fatalf("got state %v after a panicking probe, want open", b.State())
+		}
+		time.Sleep(time.Second)
+		if err := b.Do(succeed); err != nil || b.State() != BreakerClosed {
+			t.Fatalf("got err %v and state %v, want the next probe to close the breaker", err, b.State())
+		}
+		if m := b.Metrics(); m.Failures != 2 || m.Successes != 1 {
+			t.Fatalf("unexpected metrics %+v", m)
+		}
+	})
+}
+
+func TestBreakerStage(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b := NewBreaker(BreakerConfig{ConsecutiveFailures: 2, CoolDown: time.Hour})
+		calls := 0
+		down := func(ctx context.Context, v int) (int, error) {
+			calls++
+			return 0, errDown
+		}
+		policy := RetryPolicy{MaxAttempts: 1}
+		_, dead := retryAll(Retry(ctx, Generate(ctx, 1, 2, 3, 4), BreakerStage(b, down), policy))
+		if calls != 2 || len(dead) != 4 {
+			t.Fatalf("got %d calls and %d dead letters, want 2 and 4", calls, len(dead))
+		}
+		if !errors.Is(dead[3].Err, ErrBreakerOpen) {
+			t.Fatalf("got err %v, want %v", dead[3].Err, ErrBreakerOpen)
+		}
+	})
+}
+
+func TestBreakerWork(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b := NewBreaker(BreakerConfig{ConsecutiveFailures: 2, CoolDown: time.Hour})
+		// 负数表示下游失败
+		work := func(ctx context.Context, in <-chan int) <-chan int {
+			return Map(ctx, in, func(v int) int { return -v })
+		}
+		var rejected []int
+		wrapped := BreakerWork(b, work, func(r int) bool { return r < 0 }, func(v int) { rejected = append(rejected, v) })
+		in := make(chan int)
+		out := wrapped(ctx, in)
+		for _, v := range []int{1, 2, 3, 4} {
+			in <- v
+			if v <= 2 {
+				<-out
+			}
+		}
+		close(in)
+		for range out {
+		}
+		if b.State() != BreakerOpen || !slices.Equal(rejected, []int{3, 4}) {
+			t.Fatalf("got state %v and rejected %v, want open and [3 4]", b.State(), rejected)
+		}
+	})
+}
+
+func TestBreakerWorkDropsItems(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b := NewBreaker(BreakerConfig{ConsecutiveFailures: 1, CoolDown: time.Second})
+		// 只保留偶数，如 primeFinder 一类的过滤阶段
+		evens := func(ctx context.Context, in <-chan int) <-chan int {
+			out := make(chan int)
+			go func() {
+				defer close(out)
+				for v := range OrDone(ctx, in) {
+					if v%2 == 0 && Send(ctx, out, v) != nil {
+						return
+					}
+				}
+			}()
+			return out
+		}
+		var rejected []int
+		wrapped := BreakerWork(b, evens, func(r int) bool { return r < 0 }, func(v int) { rejected = append(rejected, v) })
+		in := make(chan int)
+		out := wrapped(ctx, in)
+		in <- -2
+		<-out
+		time.Sleep(time.Second)
+		// 半开状态下被过滤的试探归还名额，后续输入仍可试探
+		var got []int
+		for _, v := range []int{1, 3, 2, 4, 6} {
+			in <- v
+			if v%2 == 0 {
+				got = append(got, <-out)
+			}
+		}
+		close(in)
+		for range out {
+		}
+		if b.State() != BreakerClosed || rejected != nil || !slices.Equal(got, []int{2, 4, 6}) {
+			t.Fatalf("got state %v, rejected %v and output %v, want closed, none and [2 4 6]", b.State(), rejected, got)
+		}
+	})
+}
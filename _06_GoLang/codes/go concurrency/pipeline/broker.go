@@ -0,0 +1,226 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrBrokerClosed 表示 Broker 已关闭
+var ErrBrokerClosed = errors.New("pipeline: broker closed")
+
+// Broker 是进程内按主题发布/订阅的消息代理。
+// 每个订阅者拥有独立的缓冲通道及慢消费者策略；Broker 的 ctx 结束或调用 Close 后关闭所有订阅
+type Broker[T any] struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	log    *stageLog // 收到的项数按发布的消息计，发出的项数按送达的订阅者计
+
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription[T]]struct{}
+	closed bool
+}
+
+// Subscription 是某个主题的一个订阅者
+type Subscription[T any] struct {
+	topic   string
+	policy  Backpressure
+	c       chan T
+	done    chan struct{} // 取消订阅后关闭，使阻塞中的发布者放弃该订阅者
+	stop    sync.Once
+	dropped atomic.Int64
+
+	mu     sync.RWMutex // 发布者投递时持有读锁，关闭 c 时持有写锁
+	closed bool
+}
+
+// C 返回订阅者的接收通道，取消订阅或 Broker 关闭后该通道被关闭
+func (s *Subscription[T]) C() <-chan T { return s.c }
+
+// Topic 返回订阅的主题
+func (s *Subscription[T]) Topic() string { return s.topic }
+
+// Dropped 返回因 DropNewest/DropOldest 策略被丢弃的消息数
+func (s *Subscription[T]) Dropped() int64 { return s.dropped.Load() }
+
+func (s *Subscription[T]) halt() { s.stop.Do(func() { close(s.done) }) }
+
+// shut 关闭 s 的通道：先通过 done 释放阻塞中的发布者，再等待所有投递结束
+func (s *Subscription[T]) shut() {
+	s.halt()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.c)
+	}
+}
+
+// NewBroker 返回新的 Broker，ctx 结束时 Broker 自动关闭。
+// Broker 使用 opts 中的 WithName、WithLogger 与 WithWorkerID：创建时记录 MsgStart，
+// Close 后记录 MsgStop，ctx 结束时记录 MsgCancel
+func NewBroker[T any](ctx context.Context, opts ...Option) *Broker[T] {
+	c := newConfig(opts)
+	ctx, cancel := context.WithCancelCause(ctx)
+	b := &Broker[T]{
+		ctx:    ctx,
+		cancel: cancel,
+		log:    c.newStageLog("broker", c.worker),
+		topics: make(map[string]map[*Subscription[T]]struct{}),
+	}
+	b.log.start(0)
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		defer func() {
+			if errors.Is(context.Cause(ctx), ErrBrokerClosed) {
+				b.log.exit(nil)
+			} else {
+				b.log.exit(ctx.Err())
+			}
+		}()
+		b.closed = true
+		for _, subs := range b.topics {
+			for s := range subs {
+				s.shut()
+			}
+		}
+		b.topics = nil
+	}()
+	return b
+}
+
+// Close 关闭 Broker 及其所有订阅
+func (b *Broker[T]) Close() { b.cancel(ErrBrokerClosed) }
+
+// Subscribe 订阅 topic，buffer 为订阅者通道的缓冲容量，policy 决定缓冲已满时如何处理新消息；
+// DropNewest 与 DropOldest 需要至少 1 的缓冲
+func (b *Broker[T]) Subscribe(topic string, buffer int, policy Backpressure) (*Subscription[T], error) {
+	if buffer < 1 && (policy == DropNewest || policy == DropOldest) {
+		return nil, fmt.Errorf("pipeline: %v subscription needs a buffer, got %d", policy, buffer)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+	s := &Subscription[T]{
+		topic:  topic,
+		policy: policy,
+		c:      make(chan T, max(buffer, 0)),
+		done:   make(chan struct{}),
+	}
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[*Subscription[T]]struct{})
+	}
+	b.topics[topic][s] = struct{}{}
+	return s, nil
+}
+
+// Unsubscribe 取消订阅并关闭订阅者的通道，可重复调用
+func (b *Broker[T]) Unsubscribe(s *Subscription[T]) {
+	b.mu.Lock()
+	if _, ok := b.topics[s.topic][s]; ok {
+		delete(b.topics[s.topic], s)
+		if len(b.topics[s.topic]) == 0 {
+			delete(b.topics, s.topic)
+		}
+	}
+	b.mu.Unlock()
+	s.shut()
+}
+
+// Publish 将 v 发布给 topic 的所有订阅者，返回 v 送达的订阅者数量。
+// Block 订阅者会使 Publish 等待其接收，直到 ctx 结束 (返回 ctx.Err()) 或 Broker 关闭 (返回 ErrBrokerClosed)；
+// Disconnect 订阅者缓冲已满时被取消订阅。
+// 投递时不持有 Broker 的锁，阻塞的订阅者不会妨碍其他主题的订阅与发布
+func (b *Broker[T]) Publish(ctx context.Context, topic string, v T) (int, error) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return 0, ErrBrokerClosed
+	}
+	subs := make([]*Subscription[T], 0, len(b.topics[topic]))
+	for s := range b.topics[topic] {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+	b.log.received()
+
+	var delivered int
+	var err error
+	var slow []*Subscription[T]
+	for _, s := range subs {
+		var ok bool
+		if ok, err = b.deliver(ctx, s, v); err != nil {
+			break
+		}
+		switch {
+		case ok:
+			delivered++
+			b.log.sent()
+		case s.policy == Disconnect:
+			slow = append(slow, s)
+		case s.policy != Block:
+			s.dropped.Add(1)
+		}
+	}
+	for _, s := range slow {
+		b.Unsubscribe(s)
+	}
+	return delivered, err
+}
+
+// deliver 按 s 的策略投递 v，返回 v 是否进入了 s 的通道；
+// 投递期间持有 s 的读锁，s 的通道不会在发送途中被关闭
+func (b *Broker[T]) deliver(ctx context.Context, s *Subscription[T], v T) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return false, nil
+	}
+	select {
+	case <-s.done:
+		return false, nil
+	default:
+	}
+	if s.policy != Block {
+		select {
+		case s.c <- v:
+			return true, nil
+		default:
+		}
+		if s.policy != DropOldest {
+			return false, nil
+		}
+		// 丢弃最旧的值后再投递；dropped 计数的是被挤出的旧值
+		for {
+			select {
+			case <-s.c:
+				s.dropped.Add(1)
+			default:
+			}
+			select {
+			case s.c <- v:
+				return true, nil
+			default:
+			}
+		}
+	}
+	select {
+	case s.c <- v:
+		return true, nil
+	case <-s.done:
+		if b.ctx.Err() != nil {
+			return false, ErrBrokerClosed
+		}
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-b.ctx.Done():
+		return false, ErrBrokerClosed
+	}
+}
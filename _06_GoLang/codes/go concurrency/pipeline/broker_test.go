@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/synctest"
+)
+
+func TestBrokerPublishSubscribe(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b := NewBroker[string](ctx)
+		defer b.Close()
+		cows, _ := b.Subscribe("cows", 4, Block)
+		pigs, _ := b.Subscribe("pigs", 4, Block)
+		for _, msg := range []string{"moo", "moo"} {
+			if n, err := b.Publish(ctx, "cows", msg); n != 1 || err != nil {
+				t.Fatalf("Publish: got (%d, %v), want (1, nil)", n, err)
+			}
+		}
+		b.Publish(ctx, "pigs", "oink")
+		if n, _ := b.Publish(ctx, "horses", "neigh"); n != 0 {
+			t.Fatalf("publishing to a topic without subscribers delivered %d messages", n)
+		}
+		b.Unsubscribe(cows)
+		b.Unsubscribe(pigs)
+		// 订阅者可以用 FanIn 合并多个主题
+		got := collect(FanIn(ctx, cows.C(), pigs.C()))
+		slices.Sort(got)
+		if want := []string{"moo", "moo", "oink"}; !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestBrokerSlowConsumerPolicies(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b := NewBroker[int](ctx)
+		defer b.Close()
+		newest, _ := b.Subscribe("n", 2, DropNewest)
+		oldest, _ := b.Subscribe("n", 2, DropOldest)
+		disc, _ := b.Subscribe("n", 2, Disconnect)
+		for i := range 4 {
+			b.Publish(ctx, "n", i)
+		}
+		b.Unsubscribe(newest)
+		b.Unsubscribe(oldest)
+		if got := collect(newest.C()); !slices.Equal(got, []int{0, 1}) || newest.Dropped() != 2 {
+			t.Fatalf("drop-newest: got %v with %d dropped, want [0 1] with 2 dropped", got, newest.Dropped())
+		}
+		if got := collect(oldest.C()); !slices.Equal(got, []int{2, 3}) || oldest.Dropped() != 2 {
+			t.Fatalf("drop-oldest: got %v with %d dropped, want [2 3] with 2 dropped", got, oldest.Dropped())
+		}
+		// Disconnect 订阅者在第 3 条消息时被断开，其通道已关闭
+		if got := collect(disc.C()); !slices.Equal(got, []int{0, 1}) {
+			t.Fatalf("disconnect: got %v, want [0 1]", got)
+		}
+	})
+}
+
+func TestBrokerBlockingSubscriber(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b := NewBroker[int](ctx)
+		defer b.Close()
+		s, _ := b.Subscribe("n", 0, Block)
+		published := make(chan error)
+		go func() {
+			_, err := b.Publish(ctx, "n", 1)
+			published <- err
+		}()
+		synctest.Wait()
+		select {
+		case <-published:
+			t.Fatal("Publish should block until the subscriber receives")
+		default:
+		}
+		// 取消订阅会释放阻塞中的发布者
+		b.Unsubscribe(s)
+		if err := <-published; err != nil {
+			t.Fatal(err)
+		}
+
+		s, _ = b.Subscribe("n", 0, Block)
+		pubCtx, pubCancel := context.WithCancel(ctx)
+		go func() {
+			_, err := b.Publish(pubCtx, "n", 2)
+			published <- err
+		}()
+		synctest.Wait()
+		pubCancel()
+		if err := <-published; !errors.Is(err, context.Canceled) {
+			t.Fatalf("got err %v, want %v", err, context.Canceled)
+		}
+		b.Unsubscribe(s)
+	})
+}
+
+func TestBrokerClose(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		b := NewBroker[int](ctx)
+		s, _ := b.Subscribe("n", 0, Block)
+		published := make(chan error)
+		go func() {
+			_, err := b.Publish(context.Background(), "n", 1)
+			published <- err
+		}()
+		synctest.Wait()
+		cancel()
+		if err := <-published; !errors.Is(err, ErrBrokerClosed) {
+			t.Fatalf("got err %v, want %v", err, ErrBrokerClosed)
+		}
+		synctest.Wait()
+		if _, ok := <-s.C(); ok {
+			t.Fatal("closing the broker should close every subscription")
+		}
+		if _, err := b.Subscribe("n", 0, Block); !errors.Is(err, ErrBrokerClosed) {
+			t.Fatalf("got err %v, want %v", err, ErrBrokerClosed)
+		}
+		if _, err := b.Publish(context.Background(), "n", 1); !errors.Is(err, ErrBrokerClosed) {
+			t.Fatalf("got err %v, want %v", err, ErrBrokerClosed)
+		}
+		b.Unsubscribe(s)
+	})
+}
+
+func TestBrokerBlockedTopicIsolated(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b := NewBroker[int](ctx)
+		defer b.Close()
+		b.Subscribe("a", 0, Block) // 从不接收
+		published := make(chan error)
+		go func() {
+			_, err := b.Publish(ctx, "a", 1)
+			published <- err
+		}()
+		synctest.Wait()
+		// 阻塞在 "a" 上的发布者不影响其他主题的订阅与发布
+		s, err := b.Subscribe("b", 1, Block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := b.Publish(ctx, "b", 2); n != 1 || err != nil {
+			t.Fatalf("Publish: got (%d, %v), want (1, nil)", n, err)
+		}
+		if v := <-s.C(); v != 2 {
+			t.Fatalf("got %d, want 2", v)
+		}
+		b.Unsubscribe(s)
+		b.Close()
+		if err := <-published; !errors.Is(err, ErrBrokerClosed) {
+			t.Fatalf("got err %v, want %v", err, ErrBrokerClosed)
+		}
+	})
+}
+
+func TestBrokerDropPolicyNeedsBuffer(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		b := NewBroker[int](ctx)
+		defer b.Close()
+		for _, policy := range []Backpressure{DropNewest, DropOldest} {
+			if _, err := b.Subscribe("n", 0, policy); err == nil {
+				t.Fatalf("%v with buffer 0: want error", policy)
+			}
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Backpressure 决定下游来不及接收时如何处理新值
+type Backpressure int
+
+const (
+	Block      Backpressure = iota // 等待下游接收，拖慢上游
+	DropNewest                     // 缓冲已满时丢弃新值
+	DropOldest                     // 缓冲已满时丢弃缓冲中最旧的值，为新值腾出位置
+	Disconnect                     // 缓冲已满时断开该消费者并关闭其通道
+)
+
+func (b Backpressure) String() string {
+	switch b {
+	case Block:
+		return "block"
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	case Disconnect:
+		return "disconnect"
+	}
+	return fmt.Sprintf("Backpressure(%d)", int(b))
+}
+
+// Branch 描述 Tee 的一个输出分支
+type Branch struct {
+	Policy Backpressure
+	Buffer int // 分支通道的缓冲容量；DropNewest 与 DropOldest 需要 Buffer > 0 才能容纳积压
+}
+
+// Tee 将 in 中的每个值复制到每个分支，各分支按自己的 Backpressure 策略应对慢消费者：
+// Block 分支会拖慢所有分支，其余策略从不阻塞上游；Disconnect 分支积压时被提前关闭
+func Tee[T any](ctx context.Context, in <-chan T, branches ...Branch) []<-chan T {
+	return TeeWith(ctx, in, branches)
+}
+
+// TeeWith 同 Tee，branches 以切片传入以便接受 opts；每个值交给所有分支后计为发出一项
+func TeeWith[T any](ctx context.Context, in <-chan T, branches []Branch, opts ...Option) []<-chan T {
+	c := newConfig(opts)
+	chans := make([]chan T, len(branches))
+	outs := make([]<-chan T, len(branches))
+	for i, b := range branches {
+		chans[i] = make(chan T, max(b.Buffer, 0))
+		outs[i] = chans[i]
+	}
+	go func() {
+		defer func() {
+			for _, ch := range chans {
+				if ch != nil {
+					close(ch)
+				}
+			}
+		}()
+		pr := c.newProbe("tee")
+		l := c.newStageLog("tee", c.worker)
+		defer l.lifecycle(ctx)()
+		waiting := pr.now()
+		for {
+			var v T
+			select {
+			case <-ctx.Done():
+				return
+			case x, ok := <-in:
+				if !ok {
+					return
+				}
+				v = x
+			}
+			probeReceived(pr, waiting, in)
+			l.received()
+			sending := pr.now()
+			for i, b := range branches {
+				if chans[i] == nil {
+					continue
+				}
+				ok, connected := offer(ctx, chans[i], v, b.Policy)
+				if !ok {
+					return
+				}
+				if !connected {
+					close(chans[i])
+					chans[i] = nil
+				}
+			}
+			pr.sent(sending)
+			l.sent()
+			waiting = pr.now()
+		}
+	}()
+	return outs
+}
+
+// offer 按 policy 将 v 交给 c；ctx 结束时 ok 为 false，Disconnect 策略下 c 已满时 connected 为 false
+func offer[T any](ctx context.Context, c chan T, v T, policy Backpressure) (ok, connected bool) {
+	switch policy {
+	case Disconnect:
+		select {
+		case c <- v:
+		default:
+			return ctx.Err() == nil, false
+		}
+	case DropNewest:
+		select {
+		case c <- v:
+		default:
+		}
+	case DropOldest:
+		for {
+			select {
+			case c <- v:
+				return true, true
+			default:
+			}
+			select {
+			case <-c:
+			default:
+				if cap(c) == 0 {
+					return true, true // 无缓冲且下游未就绪，无旧值可丢弃
+				}
+			}
+		}
+	default:
+		return Send(ctx, c, v) == nil, true
+	}
+	return ctx.Err() == nil, true
+}
+
+// Bridge 将通道的通道按顺序展平为单个通道：读完一个内部通道后再读下一个
+func Bridge[T any](ctx context.Context, chanStream <-chan <-chan T, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("bridge")
+		l := c.newStageLog("bridge", c.worker)
+		defer l.lifecycle(ctx)()
+		for stream := range OrDone(ctx, chanStream) {
+			waiting := pr.now()
+			for v := range OrDone(ctx, stream) {
+				probeReceived(pr, waiting, stream)
+				l.received()
+				sending := pr.now()
+				if Send(ctx, out, v) != nil {
+					return
+				}
+				pr.sent(sending)
+				l.sent()
+				waiting = pr.now()
+			}
+		}
+	}()
+	return out
+}
+
+// Or 合并多个 done 通道：任意一个关闭 (或收到值) 或 ctx 结束时关闭返回的通道，内部例程随之退出。
+// 一次调用中的 done 通道元素类型 T 必须相同 (如都是 ctx.Done() 返回的 <-chan struct{})；
+// 没有输入时返回 ctx.Done()
+func Or[T any](ctx context.Context, dones ...<-chan T) <-chan struct{} {
+	if len(dones) == 0 {
+		return ctx.Done()
+	}
+	orDone := make(chan struct{})
+	var once sync.Once
+	for _, done := range dones {
+		go func() {
+			select {
+			case <-done:
+			case <-ctx.Done():
+			case <-orDone:
+				return
+			}
+			once.Do(func() { close(orDone) })
+		}()
+	}
+	return orDone
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"testing/synctest"
+)
+
+func TestTeeBlock(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		outs := Tee(ctx, Generate(ctx, 1, 2, 3), Branch{}, Branch{})
+		results := make([]chan []int, len(outs))
+		for i, out := range outs {
+			results[i] = make(chan []int, 1)
+			go func() { results[i] <- collect(out) }()
+		}
+		for i := range outs {
+			if got := <-results[i]; !slices.Equal(got, []int{1, 2, 3}) {
+				t.Fatalf("branch %d: got %v, want [1 2 3]", i, got)
+			}
+		}
+	})
+}
+
+func TestTeeDropPolicies(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// 只有 Block 分支被消费，两个 Drop 分支在 Tee 结束后才读取
+		outs := Tee(ctx, Generate(ctx, 1, 2, 3, 4, 5),
+			Branch{Policy: Block},
+			Branch{Policy: DropNewest, Buffer: 2},
+			Branch{Policy: DropOldest, Buffer: 2})
+		if got := collect(outs[0]); !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+			t.Fatalf("block branch: got %v, want [1 2 3 4 5]", got)
+		}
+		if got := collect(outs[1]); !slices.Equal(got, []int{1, 2}) {
+			t.Fatalf("drop-newest branch: got %v, want [1 2]", got)
+		}
+		if got := collect(outs[2]); !slices.Equal(got, []int{4, 5}) {
+			t.Fatalf("drop-oldest branch: got %v, want [4 5]", got)
+		}
+	})
+}
+
+func TestTeeDisconnect(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		outs := Tee(ctx, Generate(ctx, 1, 2, 3, 4), Branch{}, Branch{Policy: Disconnect, Buffer: 2})
+		if got := collect(outs[0]); !slices.Equal(got, []int{1, 2, 3, 4}) {
+			t.Fatalf("block branch: got %v, want [1 2 3 4]", got)
+		}
+		if got := collect(outs[1]); !slices.Equal(got, []int{1, 2}) {
+			t.Fatalf("disconnect branch: got %v, want [1 2]", got)
+		}
+	})
+}
+
+func TestTeeCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		outs := Tee(ctx, Repeat(ctx, counter(0)), Branch{}, Branch{Policy: DropNewest, Buffer: 1})
+		<-outs[0]
+		cancel()
+		for _, out := range outs {
+			for range out {
+			}
+		}
+		synctest.Wait()
+	})
+}
+
+func TestBridge(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		streams := Generate(ctx, Generate(ctx, 1, 2), Generate[int](ctx), Generate(ctx, 3))
+		if got := collect(Bridge(ctx, streams)); !slices.Equal(got, []int{1, 2, 3}) {
+			t.Fatalf("got %v, want [1 2 3]", got)
+		}
+	})
+}
+
+func TestBridgeCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		streams := Repeat(ctx, func() <-chan int { return Repeat(ctx, counter(0)) })
+		out := Bridge(ctx, streams)
+		<-out
+		cancel()
+		for range out {
+		}
+		synctest.Wait()
+	})
+}
+
+func TestOr(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		a, b, c := make(chan any), make(chan any), make(chan any)
+		done := Or(context.Background(), a, b, c)
+		synctest.Wait()
+		select {
+		case <-done:
+			t.Fatal("Or should not be done before any input is closed")
+		default:
+		}
+		close(b)
+		<-done
+		// Or 的内部例程随之全部退出
+		synctest.Wait()
+	})
+}
+
+func TestOrContextDone(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx1, cancel1 := context.WithCancel(context.Background())
+		defer cancel1()
+		ctx2, cancel2 := context.WithCancel(context.Background())
+		done := Or(context.Background(), ctx1.Done(), ctx2.Done())
+		cancel2()
+		<-done
+		if Or[int](ctx1) != ctx1.Done() {
+			t.Fatal("Or without inputs should return ctx.Done()")
+		}
+	})
+}
+
+func TestOrCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		// 没有输入会关闭时，ctx 结束使 Or 关闭且不留下例程
+		done := Or(ctx, make(chan int), make(chan int))
+		cancel()
+		<-done
+		synctest.Wait()
+	})
+}
@@ -0,0 +1,420 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+! 声明式流水线配置：阶段的实现以名字注册到 Registry，配置文件只引用名字并调整参数，
+例如在不重新编译的情况下调整 fan-out 的 worker 数量：
+
+	source: naturals
+	limit: 500
+	stages:
+	  - use: primeFinder
+	    workers: 8
+	    buffer: 16
+	    rate: {every: 1ms, burst: 10}
+	    retry: {maxAttempts: 3, baseDelay: 10ms, jitter: full}
+	  - use: sq
+*/
+
+// Duration 是可从 "150ms"、"1.5s" 等字符串解析的时长
+type Duration time.Duration
+
+func (d *Duration) parse(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"100ms\": %s", b)
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+// RateConfig 配置阶段输入的令牌桶限流，见 NewTokenBucket
+type RateConfig struct {
+	Every Duration `json:"every" yaml:"every"`
+	Burst int      `json:"burst" yaml:"burst"`
+}
+
+// RetryConfig 配置阶段逐项处理失败时的重试，见 RetryPolicy；Jitter 为 "none"、"full" 或 "equal"
+type RetryConfig struct {
+	MaxAttempts int      `json:"maxAttempts" yaml:"maxAttempts"`
+	BaseDelay   Duration `json:"baseDelay" yaml:"baseDelay"`
+	MaxDelay    Duration `json:"maxDelay" yaml:"maxDelay"`
+	Multiplier  float64  `json:"multiplier" yaml:"multiplier"`
+	Jitter      string   `json:"jitter" yaml:"jitter"`
+	Deadline    Duration `json:"deadline" yaml:"deadline"`
+}
+
+var jitters = map[string]Jitter{"": NoJitter, "none": NoJitter, "full": FullJitter, "equal": EqualJitter}
+
+func (rc RetryConfig) policy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: rc.MaxAttempts,
+		BaseDelay:   time.Duration(rc.BaseDelay),
+		MaxDelay:    time.Duration(rc.MaxDelay),
+		Multiplier:  rc.Multiplier,
+		Jitter:      jitters[rc.Jitter],
+		Deadline:    time.Duration(rc.Deadline),
+	}
+}
+
+// StageConfig 配置一个阶段
+type StageConfig struct {
+	Use     string       `json:"use" yaml:"use"`         // 注册表中的阶段名
+	Name    string       `json:"name" yaml:"name"`       // 用于度量与日志的实例名，默认为 Use
+	Workers int          `json:"workers" yaml:"workers"` // 并行 worker 数量，大于 1 时 fan-out 后再 fan-in，默认为 1
+	Buffer  int          `json:"buffer" yaml:"buffer"`   // 输出通道的缓冲容量
+	Rate    *RateConfig  `json:"rate" yaml:"rate"`
+	Retry   *RetryConfig `json:"retry" yaml:"retry"`
+}
+
+func (sc StageConfig) name() string {
+	if sc.Name == "" {
+		return sc.Use
+	}
+	return sc.Name
+}
+
+// Config 描述一条流水线：源阶段、依次串联的阶段，以及可选的输出项数上限
+type Config struct {
+	Source string        `json:"source" yaml:"source"`
+	Stages []StageConfig `json:"stages" yaml:"stages"`
+	Limit  int           `json:"limit" yaml:"limit"` // 只取前 Limit 项，0 表示不限
+}
+
+// ParseJSON 解析 JSON 配置，未知字段视为错误
+func ParseJSON(data []byte) (Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("pipeline: parse JSON config: %w", err)
+	}
+	return cfg, nil
+}
+
+// ParseYAML 解析 YAML 配置，未知字段视为错误
+func ParseYAML(data []byte) (Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("pipeline: parse YAML config: %w", err)
+	}
+	return cfg, nil
+}
+
+// LoadConfig 按扩展名 (.json、.yaml 或 .yml) 读取并解析配置文件
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	}
+	return Config{}, fmt.Errorf("pipeline: unknown config format %q", path)
+}
+
+// entry 是注册的阶段；通道以 any 传递，具体类型由注册时的泛型闭包负责断言。
+// 阶段启动的例程都计入 Group，g.Wait 返回时流水线的所有例程均已退出
+type entry struct {
+	in, out reflect.Type // 源阶段的 in 为 nil
+	source  func(g *Group) any
+	stage   func(g *Group, in any, sc StageConfig, opts []Option) any
+}
+
+// Registry 保存可在配置中按名字引用的阶段
+type Registry struct {
+	entries map[string]entry
+}
+
+// NewRegistry 返回空的注册表
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]entry)}
+}
+
+func (r *Registry) add(name string, e entry) error {
+	if _, ok := r.entries[name]; ok {
+		return fmt.Errorf("pipeline: stage %q already registered", name)
+	}
+	r.entries[name] = e
+	return nil
+}
+
+// RegisterSource 注册源阶段，fn 在 ctx 结束后应关闭其输出；g.Wait 会等到该输出关闭
+func RegisterSource[T any](r *Registry, name string, fn func(ctx context.Context) <-chan T) error {
+	return r.add(name, entry{
+		out:    reflect.TypeFor[T](),
+		source: func(g *Group) any { return track(g, fn(g.ctx)) },
+	})
+}
+
+// Register 注册逐项处理的阶段
+func Register[T, R any](r *Registry, name string, fn Stage[T, R]) error {
+	return r.add(name, entry{
+		in:  reflect.TypeFor[T](),
+		out: reflect.TypeFor[R](),
+		stage: func(g *Group, in any, sc StageConfig, opts []Option) any {
+			return buildStage(g, in.(<-chan T), fn, sc, opts)
+		},
+	})
+}
+
+// RegisterFunc 注册不会失败的逐项处理函数，如 sq
+func RegisterFunc[T, R any](r *Registry, name string, fn func(T) R) error {
+	return Register(r, name, func(_ context.Context, v T) (R, error) { return fn(v), nil })
+}
+
+// RegisterFilter 注册过滤阶段，只保留 keep 返回 true 的项，如 primeFinder
+func RegisterFilter[T any](r *Registry, name string, keep func(T) bool) error {
+	type kept struct {
+		v  T
+		ok bool
+	}
+	return r.add(name, entry{
+		in:  reflect.TypeFor[T](),
+		out: reflect.TypeFor[T](),
+		stage: func(g *Group, in any, sc StageConfig, opts []Option) any {
+			marked := buildStage(g, in.(<-chan T), func(_ context.Context, v T) (kept, error) {
+				return kept{v, keep(v)}, nil
+			}, sc, opts)
+			out := make(chan T)
+			g.Go(func() error {
+				defer close(out)
+				for k := range OrDone(g.ctx, marked) {
+					if k.ok && Send(g.ctx, out, k.v) != nil {
+						return nil
+					}
+				}
+				return nil
+			})
+			return (<-chan T)(out)
+		},
+	})
+}
+
+// buildStage 按 sc 组装限流、重试、fan-out/fan-in 与输出缓冲
+func buildStage[T, R any](g *Group, in <-chan T, fn Stage[T, R], sc StageConfig, opts []Option) <-chan R {
+	if sc.Rate != nil {
+		lim, _ := NewTokenBucket(time.Duration(sc.Rate.Every), sc.Rate.Burst) // 已由 Validate 检查
+		in = Throttle(g.ctx, in, lim, tracked(g)...)
+	}
+	if sc.Retry != nil {
+		fn = retryStage(fn, sc.Retry.policy())
+	}
+	opts = append(opts[:len(opts):len(opts)], WithName(sc.name()))
+	var out <-chan R
+	if sc.Workers > 1 {
+		outs, _ := FanOutErr(g, sc.Workers, in, fn, opts...)
+		out = FanInWith(g.ctx, outs, tracked(g)...)
+	} else {
+		out = MapErr(g, in, fn, opts...)
+	}
+	if sc.Buffer > 0 {
+		out = buffered(g, out, sc.Buffer)
+	}
+	return out
+}
+
+// retryStage 使 fn 的每次调用按 policy 重试，重试耗尽后返回最后的错误
+func retryStage[T, R any](fn Stage[T, R], policy RetryPolicy) Stage[T, R] {
+	return func(ctx context.Context, v T) (R, error) {
+		var r R
+		_, err := policy.do(ctx, func(ctx context.Context) (err error) {
+			r, err = fn(ctx, v)
+			return err
+		})
+		return r, err
+	}
+}
+
+// tracked 返回使阶段的例程计入 g 的 opts，g.Wait 因此等到这些例程退出
+func tracked(g *Group) []Option {
+	g.wg.Add(1)
+	return withExitHook(nil, g.wg.Done)
+}
+
+// track 在 g 中转发 in，g.Wait 因此等到 in 关闭 (产生 in 的例程退出)：
+// ctx 结束后不再转发，但继续读取并丢弃 in 中的值直至其关闭
+func track[T any](g *Group, in <-chan T) <-chan T {
+	out := make(chan T)
+	g.Go(func() error {
+		defer close(out)
+		for v := range in {
+			if Send(g.ctx, out, v) != nil {
+				break
+			}
+		}
+		for range in {
+		}
+		return nil
+	})
+	return out
+}
+
+// buffered 在 g 中通过容量为 size 的通道转发 in
+func buffered[T any](g *Group, in <-chan T, size int) <-chan T {
+	out := make(chan T, size)
+	g.Go(func() error {
+		defer close(out)
+		for v := range OrDone(g.ctx, in) {
+			if Send(g.ctx, out, v) != nil {
+				return nil
+			}
+		}
+		return nil
+	})
+	return out
+}
+
+// ConfigError 汇总配置中的所有问题
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "pipeline: invalid config:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// Validate 检查 cfg 引用的阶段均已注册、相邻阶段的类型一致且参数合法，
+// 返回列出所有问题的 *ConfigError
+func (r *Registry) Validate(cfg Config) error {
+	var problems []string
+	report := func(format string, args ...any) { problems = append(problems, fmt.Sprintf(format, args...)) }
+
+	var prev reflect.Type // 上一阶段的输出类型，未知时为 nil
+	prevName := fmt.Sprintf("source %q", cfg.Source)
+	switch src, ok := r.entries[cfg.Source]; {
+	case cfg.Source == "":
+		report("source: missing")
+	case !ok:
+		report("source: unknown stage %q%s", cfg.Source, r.registered())
+	case src.source == nil:
+		report("source: stage %q is not a source", cfg.Source)
+	default:
+		prev = src.out
+	}
+	if cfg.Limit < 0 {
+		report("limit: must not be negative, got %d", cfg.Limit)
+	}
+	for i, sc := range cfg.Stages {
+		at := fmt.Sprintf("stages[%d] (%s)", i, sc.name())
+		e, ok := r.entries[sc.Use]
+		switch {
+		case sc.Use == "":
+			report("stages[%d]: missing \"use\"", i)
+		case !ok:
+			report("%s: unknown stage %q%s", at, sc.Use, r.registered())
+		case e.stage == nil:
+			report("%s: source stage %q cannot be used here", at, sc.Use)
+		case prev != nil && e.in != prev:
+			report("%s: input type %v does not match output type %v of %s", at, e.in, prev, prevName)
+		}
+		if sc.Workers < 0 {
+			report("%s: workers must not be negative, got %d", at, sc.Workers)
+		}
+		if sc.Buffer < 0 {
+			report("%s: buffer must not be negative, got %d", at, sc.Buffer)
+		}
+		if sc.Rate != nil && (sc.Rate.Every <= 0 || sc.Rate.Burst < 1) {
+			report("%s: rate needs every > 0 and burst >= 1, got every %v burst %d", at, time.Duration(sc.Rate.Every), sc.Rate.Burst)
+		}
+		if sc.Retry != nil {
+			if _, ok := jitters[sc.Retry.Jitter]; !ok {
+				report("%s: retry jitter must be none, full or equal, got %q", at, sc.Retry.Jitter)
+			}
+			if sc.Retry.MaxAttempts < 1 {
+				report("%s: retry maxAttempts must be at least 1, got %d", at, sc.Retry.MaxAttempts)
+			}
+		}
+		prev, prevName = nil, at
+		if ok && e.stage != nil {
+			prev = e.out
+		}
+	}
+	if problems != nil {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// registered 列出已注册的阶段名，帮助定位拼写错误
+func (r *Registry) registered() string {
+	if len(r.entries) == 0 {
+		return " (registry is empty)"
+	}
+	return " (registered: " + strings.Join(slices.Sorted(maps.Keys(r.entries)), ", ") + ")"
+}
+
+// Build 校验 cfg 并在 g 中组装流水线，返回类型为 T 的输出；
+// 阶段的错误取消 g 并由 g.Wait 返回，opts 应用于每个阶段 (如 WithInstrument、WithLogger)
+func Build[T any](g *Group, r *Registry, cfg Config, opts ...Option) (<-chan T, error) {
+	if err := r.Validate(cfg); err != nil {
+		return nil, err
+	}
+	last := r.entries[cfg.Source].out
+	if len(cfg.Stages) > 0 {
+		last = r.entries[cfg.Stages[len(cfg.Stages)-1].Use].out
+	}
+	if want := reflect.TypeFor[T](); last != want {
+		return nil, fmt.Errorf("pipeline: config produces %v, but the caller expects %v", last, want)
+	}
+	if err := g.ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := r.entries[cfg.Source].source(g)
+	for _, sc := range cfg.Stages {
+		c = r.entries[sc.Use].stage(g, c, sc, opts)
+	}
+	out := c.(<-chan T)
+	if cfg.Limit == 0 {
+		return out, nil
+	}
+	// 取够 Limit 项后停止 g，使上游阶段退出
+	limited := make(chan T)
+	g.Go(func() error {
+		defer g.Stop()
+		defer close(limited)
+		for v := range Take(g.ctx, out, cfg.Limit) {
+			if Send(g.ctx, limited, v) != nil {
+				return nil
+			}
+		}
+		return nil
+	})
+	return limited, nil
+}
@@ -0,0 +1,330 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func primeTrial(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// testRegistry 注册测试用的阶段；flaky 对每个值的前 2 次调用返回 errTransient
+func testRegistry(t *testing.T) *Registry {
+	r := NewRegistry()
+	var mu sync.Mutex
+	calls := map[int]int{}
+	for _, err := range []error{
+		RegisterSource(r, "naturals", func(ctx context.Context) <-chan int { return Repeat(ctx, counter(0)) }),
+		RegisterSource(r, "small", func(ctx context.Context) <-chan int { return Generate(ctx, 1, 2, 3, 4, 5, 6) }),
+		RegisterFunc(r, "sq", sq),
+		RegisterFilter(r, "primeFinder", primeTrial),
+		RegisterFunc(r, "itoa", strconv.Itoa),
+		Register(r, "flaky", func(ctx context.Context, n int) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if calls[n]++; calls[n] <= 2 {
+				return 0, errTransient
+			}
+			return n, nil
+		}),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+const primesYAML = `
+source: naturals
+limit: 5
+stages:
+  - use: primeFinder
+    name: primes
+    buffer: 4
+  - use: sq
+`
+
+const primesJSON = `{
+	"source": "naturals",
+	"limit": 5,
+	"stages": [
+		{"use": "primeFinder", "name": "primes", "buffer": 4},
+		{"use": "sq"}
+	]
+}`
+
+func TestParseConfig(t *testing.T) {
+	fromYAML, err := ParseYAML([]byte(primesYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := ParseJSON([]byte(primesJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{
+		Source: "naturals",
+		Limit:  5,
+		Stages: []StageConfig{{Use: "primeFinder", Name: "primes", Buffer: 4}, {Use: "sq"}},
+	}
+	if !reflect.DeepEqual(fromYAML, want) || !reflect.DeepEqual(fromJSON, want) {
+		t.Fatalf("got YAML %+v and JSON %+v, want %+v", fromYAML, fromJSON, want)
+	}
+
+	if _, err := ParseYAML([]byte("source: naturals\nworkers: 3\n")); err == nil || !strings.Contains(err.Error(), "workers") {
+		t.Errorf("got err %v, want unknown field workers", err)
+	}
+	if _, err := ParseJSON([]byte(`{"source": "naturals", "stages": [{"use": "sq", "rate": {"every": 5}}]}`)); err == nil || !strings.Contains(err.Error(), "100ms") {
+		t.Errorf("got err %v, want a duration format error", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"p.yaml": primesYAML, "p.json": primesJSON} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Source != "naturals" || len(cfg.Stages) != 2 {
+			t.Fatalf("%s: got %+v", name, cfg)
+		}
+	}
+	toml := filepath.Join(dir, "p.toml")
+	if err := os.WriteFile(toml, []byte(`source = "naturals"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(toml); err == nil || !strings.Contains(err.Error(), "unknown config format") {
+		t.Fatalf("got err %v, want unknown config format", err)
+	}
+}
+
+func TestBuildFromConfig(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		cfg, err := ParseYAML([]byte(primesYAML))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, _ := WithContext(context.Background())
+		col := NewCollector()
+		out, err := Build[int](g, testRegistry(t), cfg, WithInstrument(col))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(out)
+		// 取够 limit 项后停止，Wait 不返回错误
+		if err := g.Wait(); err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		}
+		if want := []int{4, 9, 25, 49, 121}; !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if _, ok := col.Snapshot()["primes"]; !ok {
+			t.Fatal("stage name from config was not used for metrics")
+		}
+	})
+}
+
+func TestBuildWorkers(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		cfg := Config{Source: "small", Stages: []StageConfig{{Use: "sq", Workers: 3}, {Use: "itoa"}}}
+		g, _ := WithContext(context.Background())
+		out, err := Build[string](g, testRegistry(t), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(out)
+		if err := g.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+		if want := []string{"1", "16", "25", "36", "4", "9"}; !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestBuildRetry(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		reg := testRegistry(t)
+		cfg := Config{Source: "small", Stages: []StageConfig{{
+			Use:   "flaky",
+			Retry: &RetryConfig{MaxAttempts: 3, BaseDelay: Duration(10 * ms)},
+		}}}
+		g, _ := WithContext(context.Background())
+		out, err := Build[int](g, reg, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		got := collect(out)
+		if err := g.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, []int{1, 2, 3, 4, 5, 6}) {
+			t.Fatalf("got %v, want [1 2 3 4 5 6]", got)
+		}
+		// 每项退避 10ms + 20ms
+		if elapsed := time.Since(start); elapsed != 6*30*ms {
+			t.Fatalf("took %v, want 180ms", elapsed)
+		}
+
+		// 没有重试时第一次失败即中止流水线
+		cfg.Stages[0].Retry = nil
+		g, _ = WithContext(context.Background())
+		out, err = Build[int](g, testRegistry(t), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		collect(out)
+		if err := g.Wait(); !errors.Is(err, errTransient) {
+			t.Fatalf("got err %v, want %v", err, errTransient)
+		}
+	})
+}
+
+func TestBuildRate(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		cfg := Config{Source: "small", Stages: []StageConfig{{
+			Use:  "sq",
+			Rate: &RateConfig{Every: Duration(10 * ms), Burst: 1},
+		}}}
+		g, _ := WithContext(context.Background())
+		out, err := Build[int](g, testRegistry(t), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		collect(out)
+		if err := g.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed != 50*ms {
+			t.Fatalf("took %v, want 50ms for 6 items at 1 per 10ms", elapsed)
+		}
+	})
+}
+
+func TestBuildWaitsForStages(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		reg := testRegistry(t)
+		var closed atomic.Bool
+		// 源在 ctx 结束后还需 1s 清理才关闭输出
+		err := RegisterSource(reg, "slowClose", func(ctx context.Context) <-chan int {
+			out := make(chan int)
+			go func() {
+				defer close(out)
+				defer closed.Store(true)
+				defer time.Sleep(time.Second)
+				for i := 0; Send(ctx, out, i) == nil; i++ {
+				}
+			}()
+			return out
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := Config{Source: "slowClose", Limit: 3, Stages: []StageConfig{{
+			Use:     "primeFinder",
+			Workers: 2,
+			Buffer:  2,
+			Rate:    &RateConfig{Every: Duration(ms), Burst: 1},
+		}}}
+		g, _ := WithContext(context.Background())
+		out, err := Build[int](g, reg, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := collect(out); len(got) != 3 {
+			t.Fatalf("got %v, want 3 primes", got)
+		}
+		if err := g.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if !closed.Load() {
+			t.Fatal("Wait returned before the source closed its output")
+		}
+	})
+}
+
+func TestValidateConfig(t *testing.T) {
+	reg := testRegistry(t)
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"unknown source", Config{Source: "nope"},
+			[]string{`source: unknown stage "nope" (registered: flaky, itoa, naturals, primeFinder, small, sq)`}},
+		{"not a source", Config{Source: "sq"}, []string{`source: stage "sq" is not a source`}},
+		{"unknown stage", Config{Source: "small", Stages: []StageConfig{{Use: "sqrt"}}},
+			[]string{`stages[0] (sqrt): unknown stage "sqrt"`}},
+		{"type mismatch", Config{Source: "small", Stages: []StageConfig{{Use: "itoa"}, {Use: "sq"}}},
+			[]string{`stages[1] (sq): input type int does not match output type string of stages[0] (itoa)`}},
+		{"source in stages", Config{Source: "small", Stages: []StageConfig{{Use: "naturals"}}},
+			[]string{`stages[0] (naturals): source stage "naturals" cannot be used here`}},
+		{"bad parameters", Config{Source: "small", Limit: -1, Stages: []StageConfig{{
+			Use: "sq", Workers: -2, Buffer: -1,
+			Rate:  &RateConfig{Burst: 1},
+			Retry: &RetryConfig{Jitter: "lots"},
+		}}}, []string{
+			"limit: must not be negative, got -1",
+			"stages[0] (sq): workers must not be negative, got -2",
+			"stages[0] (sq): buffer must not be negative, got -1",
+			"stages[0] (sq): rate needs every > 0 and burst >= 1, got every 0s burst 1",
+			`stages[0] (sq): retry jitter must be none, full or equal, got "lots"`,
+			"stages[0] (sq): retry maxAttempts must be at least 1, got 0",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reg.Validate(tt.cfg)
+			var cerr *ConfigError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("got err %v, want a *ConfigError", err)
+			}
+			if len(cerr.Problems) != len(tt.want) {
+				t.Fatalf("got problems %q, want %q", cerr.Problems, tt.want)
+			}
+			for i, p := range cerr.Problems {
+				if !strings.HasPrefix(p, tt.want[i]) {
+					t.Errorf("got problem %q, want %q", p, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBuildOutputType(t *testing.T) {
+	g, _ := WithContext(context.Background())
+	defer g.Wait()
+	_, err := Build[int](g, testRegistry(t), Config{Source: "small", Stages: []StageConfig{{Use: "itoa"}}})
+	if err == nil || !strings.Contains(err.Error(), "config produces string, but the caller expects int") {
+		t.Fatalf("got err %v, want an output type mismatch", err)
+	}
+}
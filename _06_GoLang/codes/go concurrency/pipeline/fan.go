@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FanOut 启动 n 个 work 例程共同消费 in，返回各例程的输出通道；ctx 已结束时返回 ctx.Err()
+func FanOut[T, R any](ctx context.Context, n int, in <-chan T, work func(ctx context.Context, in <-chan T) <-chan R) ([]<-chan R, error) {
+	if err := checkWorkers(ctx, n); err != nil {
+		return nil, err
+	}
+	outs := make([]<-chan R, n)
+	for i := range outs {
+		outs[i] = work(ctx, in)
+	}
+	return outs, nil
+}
+
+// FanIn 合并多个通道为一个通道，所有输入关闭或 ctx 结束后关闭输出
+func FanIn[T any](ctx context.Context, chans ...<-chan T) <-chan T {
+	return FanInWith(ctx, chans)
+}
+
+// FanInWith 同 FanIn，chans 以切片传入以便接受 opts
+func FanInWith[T any](ctx context.Context, chans []<-chan T, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	pr := c.newProbe("fan_in")
+	l := c.newStageLog("fan_in", c.worker)
+	var wg sync.WaitGroup
+	out := make(chan T)
+	transfer := func(in <-chan T) {
+		defer wg.Done()
+		waiting := pr.now()
+		for {
+			var v T
+			select {
+			case <-ctx.Done():
+				return
+			case x, ok := <-in:
+				if !ok {
+					return
+				}
+				v = x
+			}
+			probeReceived(pr, waiting, in)
+			l.received()
+			sending := pr.now()
+			if Send(ctx, out, v) != nil {
+				return
+			}
+			pr.sent(sending)
+			l.sent()
+			waiting = pr.now()
+		}
+	}
+	wg.Add(len(chans))
+	for _, in := range chans {
+		go transfer(in)
+	}
+	exit := l.lifecycle(ctx)
+	go func() {
+		wg.Wait()
+		exit()
+		close(out)
+		c.exited()
+	}()
+	return out
+}
+
+// checkWorkers 检查 worker 数量与 ctx 是否允许启动新的 fan-out
+func checkWorkers(ctx context.Context, n int) error {
+	if n < 1 {
+		return fmt.Errorf("pipeline: n(%d) is less than 1", n)
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/synctest"
+)
+
+func square(ctx context.Context, in <-chan int) <-chan int {
+	return Map(ctx, in, sq)
+}
+
+func TestFanOutFanIn(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8)
+		outs, err := FanOut(ctx, 3, in, square)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(outs) != 3 {
+			t.Fatalf("got %d workers, want 3", len(outs))
+		}
+		got := collect(FanIn(ctx, outs...))
+		slices.Sort(got)
+		want := []int{1, 4, 9, 16, 25, 36, 49, 64}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestFanOutInvalidN(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if _, err := FanOut(ctx, 0, Generate[int](ctx), square); err == nil {
+			t.Fatal("expected error for n < 1")
+		}
+	})
+}
+
+func TestFanOutCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := FanOut(ctx, 2, Generate[int](ctx), square); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got err %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestFanInCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		a, b := make(chan int), make(chan int)
+		out := FanIn(ctx, a, b)
+		cancel()
+		if _, ok := <-out; ok {
+			t.Fatal("FanIn should close its output once ctx is canceled")
+		}
+	})
+}
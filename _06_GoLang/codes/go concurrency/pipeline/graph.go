@@ -0,0 +1,270 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// NodeKind 是图中阶段的类别
+type NodeKind string
+
+const (
+	KindSource NodeKind = "source"
+	KindMap    NodeKind = "map"
+	KindWorker NodeKind = "worker" // FanOutFlow 启动的 worker
+	KindFanIn  NodeKind = "fanin"
+	KindTee    NodeKind = "tee"
+	KindStage  NodeKind = "stage" // 由 Connect 接入的任意阶段
+)
+
+// Node 是图中的一个阶段；Name 同时用作度量中的阶段名
+type Node struct {
+	ID   int
+	Name string
+	Kind NodeKind
+}
+
+// Edge 是两个阶段之间的通道，Label 描述连接方式 (如 Tee 分支的背压策略)
+type Edge struct {
+	From, To int
+	Label    string
+}
+
+// Graph 在构建流水线的同时记录阶段与连接，可渲染为 Graphviz DOT 或 Mermaid。
+// 通过 *Flow 函数 (GenerateFlow、MapFlow、FanOutFlow、FanInFlow、TeeFlow) 构建的阶段会以节点名作为 WithName 启动，
+// 因此向 NewGraph 传入 WithInstrument(collector) 后即可在渲染时叠加该阶段的度量
+type Graph struct {
+	ctx  context.Context
+	opts []Option
+
+	mu    sync.Mutex
+	nodes []Node
+	edges []Edge
+}
+
+// NewGraph 返回空图；ctx 与 opts 用于图中启动的每个阶段
+func NewGraph(ctx context.Context, opts ...Option) *Graph {
+	return &Graph{ctx: ctx, opts: opts}
+}
+
+// Flow 是图中某个阶段的输出
+type Flow[T any] struct {
+	g     *Graph
+	node  int
+	c     <-chan T
+	label string // 接入下游时连接的标注，如 Tee 分支的背压策略
+}
+
+// C 返回该阶段的输出通道
+func (f Flow[T]) C() <-chan T { return f.c }
+
+// Graph 返回 f 所属的图
+func (f Flow[T]) Graph() *Graph { return f.g }
+
+// link 记录从 f 到节点 to 的连接
+func (f Flow[T]) link(to int) { f.g.addEdge(f.node, to, f.label) }
+
+func (g *Graph) addNode(name string, kind NodeKind) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id := len(g.nodes)
+	g.nodes = append(g.nodes, Node{ID: id, Name: name, Kind: kind})
+	return id
+}
+
+func (g *Graph) addEdge(from, to int, label string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.edges = append(g.edges, Edge{From: from, To: to, Label: label})
+}
+
+// stageOpts 返回以 name 命名阶段的选项
+func (g *Graph) stageOpts(name string, opts []Option) []Option {
+	rt := make([]Option, 0, len(g.opts)+len(opts)+1)
+	rt = append(rt, g.opts...)
+	rt = append(rt, WithName(name))
+	return append(rt, opts...)
+}
+
+// Nodes 返回已记录的阶段
+func (g *Graph) Nodes() []Node {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Node(nil), g.nodes...)
+}
+
+// Edges 返回已记录的连接
+func (g *Graph) Edges() []Edge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Edge(nil), g.edges...)
+}
+
+// Source 将已有的通道作为源阶段加入图
+func Source[T any](g *Graph, name string, c <-chan T) Flow[T] {
+	return Flow[T]{g: g, node: g.addNode(name, KindSource), c: c}
+}
+
+// GenerateFlow 以 Generate(values...) 作为源阶段
+func GenerateFlow[T any](g *Graph, name string, values ...T) Flow[T] {
+	return Source(g, name, GenerateWith(g.ctx, values, g.stageOpts(name, nil)...))
+}
+
+// MapFlow 在 f 之后接入 Map 阶段
+func MapFlow[T, R any](f Flow[T], name string, fn func(T) R, opts ...Option) Flow[R] {
+	id := f.g.addNode(name, KindMap)
+	f.link(id)
+	return Flow[R]{g: f.g, node: id, c: Map(f.g.ctx, f.c, fn, f.g.stageOpts(name, opts)...)}
+}
+
+// FanOutFlow 在 f 之后启动 n 个 Map worker 共同消费 f，worker 节点名为 name[i]
+func FanOutFlow[T, R any](f Flow[T], name string, n int, fn func(T) R, opts ...Option) ([]Flow[R], error) {
+	if err := checkWorkers(f.g.ctx, n); err != nil {
+		return nil, err
+	}
+	flows := make([]Flow[R], n)
+	for i := range flows {
+		wname := fmt.Sprintf("%s[%d]", name, i)
+		id := f.g.addNode(wname, KindWorker)
+		f.link(id)
+		flows[i] = Flow[R]{g: f.g, node: id, c: Map(f.g.ctx, f.c, fn, withWorker(f.g.stageOpts(wname, opts), i)...)}
+	}
+	return flows, nil
+}
+
+// FanInFlow 合并多个 Flow；flows 必须属于同一张图且不能为空
+func FanInFlow[T any](name string, flows ...Flow[T]) Flow[T] {
+	g := flows[0].g
+	id := g.addNode(name, KindFanIn)
+	chans := make([]<-chan T, len(flows))
+	for i, f := range flows {
+		f.link(id)
+		chans[i] = f.c
+	}
+	return Flow[T]{g: g, node: id, c: FanInWith(g.ctx, chans, g.stageOpts(name, nil)...)}
+}
+
+// TeeFlow 在 f 之后接入 Tee；各分支共享 tee 节点，分支接入下游时的连接以其背压策略标注
+func TeeFlow[T any](f Flow[T], name string, branches ...Branch) []Flow[T] {
+	id := f.g.addNode(name, KindTee)
+	f.link(id)
+	outs := TeeWith(f.g.ctx, f.c, branches, f.g.stageOpts(name, nil)...)
+	flows := make([]Flow[T], len(outs))
+	for i, c := range outs {
+		flows[i] = Flow[T]{g: f.g, node: id, c: c, label: branches[i].Policy.String()}
+	}
+	return flows
+}
+
+// Connect 在 f 之后接入任意阶段 (如 Take、Throttle、Batch)
+func Connect[T, R any](f Flow[T], name string, stage func(ctx context.Context, in <-chan T) <-chan R) Flow[R] {
+	id := f.g.addNode(name, KindStage)
+	f.link(id)
+	return Flow[R]{g: f.g, node: id, c: stage(f.g.ctx, f.c)}
+}
+
+// edgeLabel 返回叠加了度量的边标注。边上的项数取自该边独占的一端：
+// 下游只有这一条入边时取其收到数，否则上游只有这一条出边时取其发出数
+// (扇出、扇入与 Tee 的计数无法分摊到各条边)；下游有度量时附加其输入通道的占用
+func edgeLabel(e Edge, nodes []Node, deg degrees, m map[string]StageMetrics) string {
+	parts := []string{}
+	if e.Label != "" {
+		parts = append(parts, e.Label)
+	}
+	if m != nil {
+		from, fromOK := m[nodes[e.From].Name]
+		to, toOK := m[nodes[e.To].Name]
+		switch {
+		case toOK && deg.in[e.To] == 1:
+			parts = append(parts, fmt.Sprintf("%d items", to.In))
+		case fromOK && deg.out[e.From] == 1:
+			parts = append(parts, fmt.Sprintf("%d items", from.Out))
+		}
+		if toOK && to.Capacity > 0 {
+			parts = append(parts, fmt.Sprintf("%d/%d buffered", to.Length, to.Capacity))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// degrees 是各节点的入边数与出边数
+type degrees struct{ in, out map[int]int }
+
+func degreesOf(edges []Edge) degrees {
+	d := degrees{in: make(map[int]int), out: make(map[int]int)}
+	for _, e := range edges {
+		d.in[e.To]++
+		d.out[e.From]++
+	}
+	return d
+}
+
+// DOT 将图渲染为 Graphviz DOT；m 不为 nil 时 (如 Collector.Snapshot()) 在边上叠加度量
+func (g *Graph) DOT(m map[string]StageMetrics) string {
+	nodes, edges := g.Nodes(), g.Edges()
+	deg := degreesOf(edges)
+	var sb strings.Builder
+	sb.WriteString("digraph pipeline {\n\trankdir=LR;\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&sb, "\tn%d [label=%s, shape=%s];\n", n.ID, dotQuote(n.Name), dotShape(n.Kind))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&sb, "\tn%d -> n%d", e.From, e.To)
+		if label := edgeLabel(e, nodes, deg, m); label != "" {
+			fmt.Fprintf(&sb, " [label=%s]", dotQuote(label))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Mermaid 将图渲染为 Mermaid 流程图；m 不为 nil 时在边上叠加度量
+func (g *Graph) Mermaid(m map[string]StageMetrics) string {
+	nodes, edges := g.Nodes(), g.Edges()
+	deg := degreesOf(edges)
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for _, n := range nodes {
+		l, r := mermaidShape(n.Kind)
+		fmt.Fprintf(&sb, "\tn%d%s\"%s\"%s\n", n.ID, l, mermaidEscape(n.Name), r)
+	}
+	for _, e := range edges {
+		if label := edgeLabel(e, nodes, deg, m); label != "" {
+			fmt.Fprintf(&sb, "\tn%d -->|\"%s\"| n%d\n", e.From, mermaidEscape(label), e.To)
+		} else {
+			fmt.Fprintf(&sb, "\tn%d --> n%d\n", e.From, e.To)
+		}
+	}
+	return sb.String()
+}
+
+func dotShape(k NodeKind) string {
+	switch k {
+	case KindSource:
+		return "invhouse"
+	case KindFanIn, KindTee:
+		return "diamond"
+	}
+	return "box"
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func mermaidShape(k NodeKind) (l, r string) {
+	switch k {
+	case KindSource:
+		return "([", "])"
+	case KindFanIn, KindTee:
+		return "{", "}"
+	}
+	return "[", "]"
+}
+
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s)
+}
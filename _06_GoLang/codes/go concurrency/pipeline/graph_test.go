@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"testing/synctest"
+)
+
+// buildGraph 构建 generate -> sq[0..1] -> merge -> tee -> {double, take}
+func buildGraph(t *testing.T, ctx context.Context, opts ...Option) (*Graph, Flow[int], Flow[int]) {
+	g := NewGraph(ctx, opts...)
+	src := GenerateFlow(g, "generate", 1, 2, 3, 4)
+	workers, err := FanOutFlow(src, "sq", 2, sq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches := TeeFlow(FanInFlow("merge", workers...), "tee",
+		Branch{Policy: Block}, Branch{Policy: Block, Buffer: 4})
+	double := MapFlow(branches[0], "double", func(n int) int { return 2 * n })
+	first := Connect(branches[1], "take", func(ctx context.Context, in <-chan int) <-chan int {
+		return Take(ctx, in, 10)
+	})
+	return g, double, first
+}
+
+func TestGraphRecordsEdges(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		g, double, first := buildGraph(t, ctx)
+		go collect(first.C())
+		got := collect(double.C())
+		slices.Sort(got)
+		if want := []int{2, 8, 18, 32}; !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		var names []string
+		for _, n := range g.Nodes() {
+			names = append(names, n.Name+":"+string(n.Kind))
+		}
+		wantNodes := []string{"generate:source", "sq[0]:worker", "sq[1]:worker", "merge:fanin", "tee:tee", "double:map", "take:stage"}
+		if !slices.Equal(names, wantNodes) {
+			t.Fatalf("got nodes %v, want %v", names, wantNodes)
+		}
+		wantEdges := []Edge{{0, 1, ""}, {0, 2, ""}, {1, 3, ""}, {2, 3, ""}, {3, 4, ""}, {4, 5, "block"}, {4, 6, "block"}}
+		if got := g.Edges(); !slices.Equal(got, wantEdges) {
+			t.Fatalf("got edges %v, want %v", got, wantEdges)
+		}
+	})
+}
+
+func TestGraphRender(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		g, double, first := buildGraph(t, ctx)
+		go collect(first.C())
+		collect(double.C())
+
+		wantDOT := `digraph pipeline {
+	rankdir=LR;
+	n0 [label="generate", shape=invhouse];
+	n1 [label="sq[0]", shape=box];
+	n2 [label="sq[1]", shape=box];
+	n3 [label="merge", shape=diamond];
+	n4 [label="tee", shape=diamond];
+	n5 [label="double", shape=box];
+	n6 [label="take", shape=box];
+	n0 -> n1;
+	n0 -> n2;
+	n1 -> n3;
+	n2 -> n3;
+	n3 -> n4;
+	n4 -> n5 [label="block"];
+	n4 -> n6 [label="block"];
+}
+`
+		if got := g.DOT(nil); got != wantDOT {
+			t.Fatalf("got DOT\n%s\nwant\n%s", got, wantDOT)
+		}
+		wantMermaid := `flowchart LR
+	n0(["generate"])
+	n1["sq[0]"]
+	n2["sq[1]"]
+	n3{"merge"}
+	n4{"tee"}
+	n5["double"]
+	n6["take"]
+	n0 --> n1
+	n0 --> n2
+	n1 --> n3
+	n2 --> n3
+	n3 --> n4
+	n4 -->|"block"| n5
+	n4 -->|"block"| n6
+`
+		if got := g.Mermaid(nil); got != wantMermaid {
+			t.Fatalf("got Mermaid\n%s\nwant\n%s", got, wantMermaid)
+		}
+	})
+}
+
+func TestGraphMetricsOverlay(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		col := NewCollector()
+		g, double, first := buildGraph(t, ctx, WithInstrument(col))
+		go collect(first.C())
+		collect(double.C())
+
+		m := col.Snapshot()
+		if n := m["sq[0]"].In + m["sq[1]"].In; n != 4 {
+			t.Fatalf("workers received %d items, want 4", n)
+		}
+		dot := g.DOT(m)
+		for _, line := range []string{
+			// 源阶段无度量，边上的项数取自下游 worker
+			fmt.Sprintf(`n0 -> n1 [label="%d items"];`, m["sq[0]"].In),
+			fmt.Sprintf(`n1 -> n3 [label="%d items"];`, m["sq[0]"].Out),
+			`n4 -> n5 [label="block, 4 items"];`,
+			`n4 -> n6 [label="block"];`,
+		} {
+			if !strings.Contains(dot, "\t"+line+"\n") {
+				t.Errorf("DOT missing %s\n%s", line, dot)
+			}
+		}
+		if mm := g.Mermaid(m); !strings.Contains(mm, `n4 -->|"block, 4 items"| n5`) {
+			t.Errorf("Mermaid missing metrics overlay\n%s", mm)
+		}
+	})
+}
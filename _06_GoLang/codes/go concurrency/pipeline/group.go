@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Stage 是可能失败的逐项处理函数
+type Stage[T, R any] func(ctx context.Context, v T) (R, error)
+
+// Group 为一组可失败的阶段提供共享取消 (类似 errgroup)：
+// 第一个错误会取消整条流水线，消费者读完输出后由 Wait 取回该错误
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+}
+
+// WithContext 返回新的 Group 及其派生的 ctx；Group 中的阶段都应使用该 ctx
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := &Group{ctx: ctx, cancel: cancel}
+	return g, ctx
+}
+
+// Go 在新例程中运行 fn，fn 返回的非 nil 错误将取消整个 Group
+func (g *Group) Go(fn func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := fn(); err != nil {
+			g.Fail(err)
+		}
+	}()
+}
+
+// Fail 记录 err (只保留第一个) 并取消 Group 的 ctx
+func (g *Group) Fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel(err)
+	})
+}
+
+// Stop 提前停止 Group (如下游已取得足够的项)：取消 ctx 但不记录错误，
+// 此后各阶段因取消而产生的错误也不再记录；Stop 前已记录的错误仍由 Wait 返回
+func (g *Group) Stop() {
+	g.once.Do(func() { g.cancel(nil) })
+}
+
+// Wait 等待 Group 中的所有例程退出，返回第一个错误
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(nil)
+	return g.err
+}
+
+// MapErr 对 in 中的每个值调用 fn 并发送结果到下游；
+// fn 出错、AbortPipeline 策略下 fn 发生 panic 或 ctx 结束时取消整个 Group 并关闭输出
+func MapErr[T, R any](g *Group, in <-chan T, fn Stage[T, R], opts ...Option) <-chan R {
+	c := newConfig(opts)
+	out := make(chan R)
+	g.wg.Add(1)
+	l := c.newStageLog("map_err", c.worker)
+	c.spawn(l, func() error {
+		p := c.newPulse()
+		defer p.stop()
+		pr := c.newProbe("map_err")
+		waiting := pr.now()
+		for {
+			select {
+			case <-g.ctx.Done():
+				return g.ctx.Err()
+			case <-p.C():
+				p.beat()
+			case v, ok := <-in:
+				if !ok {
+					// 上游可能因 ctx 结束而关闭，此时与直接观察到 ctx 结束一样报告取消
+					return g.ctx.Err()
+				}
+				probeReceived(pr, waiting, in)
+				l.received()
+				start := pr.now()
+				var r R
+				var err error
+				if perr := protect(func() { r, err = fn(g.ctx, v) }); perr != nil {
+					if err := c.recovered(l, perr); err != nil {
+						return err
+					}
+					waiting = pr.now()
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				pr.processed(start)
+				p.work()
+				sending := pr.now()
+				if err := sendPulsing(g.ctx, out, r, p); err != nil {
+					return err
+				}
+				pr.sent(sending)
+				l.sent()
+				waiting = pr.now()
+			}
+		}
+	}, func(err error) {
+		close(out)
+		if err != nil {
+			g.Fail(err)
+		}
+		g.wg.Done()
+	})
+	return out
+}
+
+// FanOutErr 启动 n 个 MapErr 例程共同消费 in，返回各例程的输出通道
+func FanOutErr[T, R any](g *Group, n int, in <-chan T, fn Stage[T, R], opts ...Option) ([]<-chan R, error) {
+	if err := checkWorkers(g.ctx, n); err != nil {
+		return nil, err
+	}
+	outs := make([]<-chan R, n)
+	for i := range outs {
+		outs[i] = MapErr(g, in, fn, withWorker(opts, i)...)
+	}
+	return outs, nil
+}
@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/synctest"
+)
+
+var errOdd = errors.New("odd number")
+
+func sqErr(ctx context.Context, n int) (int, error) {
+	return n * n, nil
+}
+
+func TestMapErr(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		got := collect(MapErr(g, Generate(ctx, 1, 2, 3), sqErr))
+		if err := g.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, []int{1, 4, 9}) {
+			t.Fatalf("got %v, want [1 4 9]", got)
+		}
+	})
+}
+
+func TestMapErrFirstError(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		failOn3 := func(ctx context.Context, n int) (int, error) {
+			if n == 3 {
+				return 0, errOdd
+			}
+			return n, nil
+		}
+		got := collect(MapErr(g, Repeat(ctx, counter(1)), failOn3))
+		if err := g.Wait(); !errors.Is(err, errOdd) {
+			t.Fatalf("got err %v, want %v", err, errOdd)
+		}
+		if !slices.Equal(got, []int{1, 2}) {
+			t.Fatalf("got %v, want [1 2]", got)
+		}
+		if ctx.Err() == nil {
+			t.Fatal("the first error should cancel the group context")
+		}
+	})
+}
+
+func TestFanOutErrCancelsSiblings(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		failOdd := func(ctx context.Context, n int) (int, error) {
+			if n%2 == 1 {
+				return 0, errOdd
+			}
+			return n, nil
+		}
+		outs, err := FanOutErr(g, 4, Repeat(ctx, counter(0)), failOdd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range FanIn(ctx, outs...) {
+		}
+		if err := g.Wait(); !errors.Is(err, errOdd) {
+			t.Fatalf("got err %v, want %v", err, errOdd)
+		}
+	})
+}
+
+func TestGroupParentCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		g, ctx := WithContext(parent)
+		out := MapErr(g, Repeat(ctx, counter(0)), sqErr)
+		<-out
+		cancel()
+		for range out {
+		}
+		if err := g.Wait(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got err %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestFanOutErrInvalidN(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		if _, err := FanOutErr(g, 0, Generate[int](ctx), sqErr); err == nil {
+			t.Fatal("expected error for n < 1")
+		}
+	})
+}
+
+func TestGroupStop(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		out := MapErr(g, Repeat(ctx, counter(0)), sqErr)
+		got := []int{<-out, <-out, <-out}
+		g.Stop()
+		collect(out)
+		// 各阶段因取消而退出，但 Stop 不是错误
+		if err := g.Wait(); err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		}
+		if !slices.Equal(got, []int{0, 1, 4}) {
+			t.Fatalf("got %v, want [0 1 4]", got)
+		}
+	})
+}
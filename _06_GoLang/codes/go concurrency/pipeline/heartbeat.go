@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Heartbeat 是阶段例程发出的存活信号
+type Heartbeat struct {
+	Time      time.Time
+	Processed int64 // 该例程已处理的项数
+}
+
+// WithHeartbeat 使阶段在 hb 上发出心跳：每隔 interval 一次 (interval <= 0 时不按时间发出)，
+// 并在每处理完一项后一次。心跳以非阻塞方式发送，hb 来不及接收时丢弃，不会拖慢阶段
+func WithHeartbeat(hb chan<- Heartbeat, interval time.Duration) Option {
+	return func(c *config) {
+		c.heartbeat = hb
+		c.pulseInterval = interval
+	}
+}
+
+// pulse 为一个阶段例程发出心跳，nil 表示未启用心跳
+type pulse struct {
+	hb        chan<- Heartbeat
+	ticker    *time.Ticker
+	processed int64
+}
+
+func (c *config) newPulse() *pulse {
+	if c.heartbeat == nil {
+		return nil
+	}
+	p := &pulse{hb: c.heartbeat}
+	if c.pulseInterval > 0 {
+		p.ticker = time.NewTicker(c.pulseInterval)
+	}
+	return p
+}
+
+// C 返回按时间发出心跳的定时通道，未启用时返回 nil (在 select 中永不就绪)
+func (p *pulse) C() <-chan time.Time {
+	if p == nil || p.ticker == nil {
+		return nil
+	}
+	return p.ticker.C
+}
+
+func (p *pulse) beat() {
+	if p == nil {
+		return
+	}
+	select {
+	case p.hb <- Heartbeat{Time: time.Now(), Processed: p.processed}:
+	default:
+	}
+}
+
+// work 记录处理完一项并发出心跳
+func (p *pulse) work() {
+	if p == nil {
+		return
+	}
+	p.processed++
+	p.beat()
+}
+
+func (p *pulse) stop() {
+	if p != nil && p.ticker != nil {
+		p.ticker.Stop()
+	}
+}
+
+// sendPulsing 与 Send 相同，但在等待下游期间继续按时间发出心跳
+func sendPulsing[T any](ctx context.Context, out chan<- T, v T, p *pulse) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- v:
+			return nil
+		case <-p.C():
+			p.beat()
+		}
+	}
+}
+
+// Steward 启动并监视一个阶段：start 应以 WithHeartbeat(hb, interval) 启动阶段并返回其输出。
+// 超过 timeout 既没有心跳也没有输出时，Steward 认为阶段已卡住，取消其 ctx 并重新调用 start，
+// 新阶段的输出接续在原输出之后；阶段的输出正常关闭或 ctx 结束时关闭输出。
+// 每次重新调用 start 记录为带 restart 属性的 MsgStart。
+// 注意：卡在忽略 ctx 的函数中的旧例程无法被强制结束
+func Steward[T any](ctx context.Context, timeout time.Duration, start func(ctx context.Context, hb chan<- Heartbeat) <-chan T, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		l := c.newStageLog("steward", c.worker)
+		defer func() { l.exit(ctx.Err()) }()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		for restarts := 0; ; restarts++ {
+			l.start(restarts)
+			wardCtx, cancel := context.WithCancel(ctx)
+			hb := make(chan Heartbeat, 1)
+			ward := start(wardCtx, hb)
+			timer.Reset(timeout)
+		watch:
+			for {
+				select {
+				case <-ctx.Done():
+					cancel()
+					return
+				case <-hb:
+					timer.Reset(timeout)
+				case <-timer.C:
+					break watch
+				case v, ok := <-ward:
+					if !ok {
+						cancel()
+						return
+					}
+					l.received()
+					if Send(ctx, out, v) != nil {
+						cancel()
+						return
+					}
+					l.sent()
+					timer.Reset(timeout)
+				}
+			}
+			cancel()
+		}
+	}()
+	return out
+}
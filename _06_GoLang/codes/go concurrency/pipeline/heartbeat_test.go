@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestMapHeartbeat(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		hb := make(chan Heartbeat, 10)
+		in := make(chan int)
+		out := Map(ctx, in, sq, WithHeartbeat(hb, 10*ms))
+
+		// 空闲期间按时间发出心跳
+		time.Sleep(25 * ms)
+		synctest.Wait()
+		if n := len(hb); n != 2 {
+			t.Fatalf("got %d idle heartbeats, want 2", n)
+		}
+		for range 2 {
+			if b := <-hb; b.Processed != 0 {
+				t.Fatalf("got Processed %d, want 0", b.Processed)
+			}
+		}
+
+		// 每处理完一项发出一次心跳
+		go func() { in <- 3 }()
+		if v := <-out; v != 9 {
+			t.Fatalf("got %d, want 9", v)
+		}
+		if b := <-hb; b.Processed != 1 {
+			t.Fatalf("got Processed %d, want 1", b.Processed)
+		}
+		close(in)
+		collect(out)
+	})
+}
+
+func TestHeartbeatWhileBlockedOnSend(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		hb := make(chan Heartbeat, 10)
+		out := Repeat(ctx, counter(0), WithHeartbeat(hb, 10*ms))
+		// 下游不读取时，例程仍在等待发送期间继续发出心跳
+		time.Sleep(35 * ms)
+		synctest.Wait()
+		if n := len(hb); n != 4 {
+			t.Fatalf("got %d heartbeats, want 4 (1 item + 3 ticks)", n)
+		}
+		if v := <-out; v != 0 {
+			t.Fatalf("got %d, want 0", v)
+		}
+	})
+}
+
+func TestStewardRestartsStuckWard(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var next, starts atomic.Int64
+		out := Steward(ctx, 50*ms, func(ctx context.Context, hb chan<- Heartbeat) <-chan int {
+			stuck := starts.Add(1) == 1
+			return Repeat(ctx, func() int {
+				v := int(next.Add(1) - 1)
+				if stuck && v == 3 {
+					// 第一个例程在第 3 项卡住，只能由 ctx 唤醒
+					<-ctx.Done()
+				}
+				return v
+			}, WithHeartbeat(hb, 10*ms))
+		})
+		start := time.Now()
+		got := collect(Take(ctx, out, 6))
+		if !slices.Equal(got, []int{0, 1, 2, 4, 5, 6}) {
+			t.Fatalf("got %v, want [0 1 2 4 5 6]", got)
+		}
+		if n := starts.Load(); n != 2 {
+			t.Fatalf("ward started %d times, want 2", n)
+		}
+		if elapsed := time.Since(start); elapsed != 50*ms {
+			t.Fatalf("restart after %v, want 50ms", elapsed)
+		}
+	})
+}
+
+func TestStewardWardDone(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		starts := 0
+		out := Steward(ctx, 50*ms, func(ctx context.Context, hb chan<- Heartbeat) <-chan int {
+			starts++
+			return Map(ctx, Generate(ctx, 1, 2, 3), sq, WithHeartbeat(hb, 10*ms))
+		})
+		if got := collect(out); !slices.Equal(got, []int{1, 4, 9}) {
+			t.Fatalf("got %v, want [1 4 9]", got)
+		}
+		if starts != 1 {
+			t.Fatalf("ward started %d times, want 1", starts)
+		}
+	})
+}
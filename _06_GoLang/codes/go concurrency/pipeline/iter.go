@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"iter"
+)
+
+// Values 返回迭代流水线输出的 iter.Seq：每次迭代以派生的 ctx 调用 build 构建流水线，
+// 消费者 break 或迭代结束时取消该 ctx，使 build 启动的上游例程全部退出
+//
+//	for v := range Values(ctx, func(ctx context.Context) <-chan int {
+//		return Map(ctx, Repeat(ctx, counter(0)), sq)
+//	}) {
+//		if v > 100 {
+//			break // 取消 Repeat 与 Map
+//		}
+//	}
+func Values[T any](ctx context.Context, build func(ctx context.Context) <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		for v := range OrDone(ctx, build(ctx)) {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// ValuesErr 与 Values 相同，但在 Group 中构建可失败的流水线：依次产出 (v, nil)，
+// 流水线失败时最后产出 (零值, err)。build 收到 Group 及其派生的 ctx，源阶段 (如 Generate) 应使用该 ctx；
+// 消费者 break (或循环体 panic) 时停止 Group (取消该 ctx) 并等待 Group 中的例程退出
+//
+//	for v, err := range ValuesErr(ctx, func(ctx context.Context, g *Group) <-chan int {
+//		return MapErr(g, Generate(ctx, 1, 2, 3), parse)
+//	}) {
+//		...
+//	}
+func ValuesErr[T any](ctx context.Context, build func(ctx context.Context, g *Group) <-chan T) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		g, ctx := WithContext(ctx)
+		defer func() {
+			g.Stop()
+			g.Wait()
+		}()
+		for v := range build(ctx, g) {
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := g.Wait(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// FromSeq 在新例程中迭代 seq 并将其值发送到返回的通道；ctx 结束时停止迭代 (相当于 break) 并关闭通道
+func FromSeq[T any](ctx context.Context, seq iter.Seq[T]) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for v := range seq {
+			if Send(ctx, out, v) != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// FromSeq2 与 FromSeq 相同，每对值包装为 Pair 发送
+func FromSeq2[K, V any](ctx context.Context, seq iter.Seq2[K, V]) <-chan Pair[K, V] {
+	out := make(chan Pair[K, V])
+	go func() {
+		defer close(out)
+		for k, v := range seq {
+			if Send(ctx, out, Pair[K, V]{k, v}) != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Pairs 是 FromSeq2 的逆操作：与 Values 相同，但将 Pair 通道迭代为 iter.Seq2
+func Pairs[K, V any](ctx context.Context, build func(ctx context.Context) <-chan Pair[K, V]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for p := range Values(ctx, build) {
+			if !yield(p.Key, p.Val) {
+				return
+			}
+		}
+	}
+}
+
+// Pair 是 iter.Seq2 产出的一对值
+type Pair[K, V any] struct {
+	Key K
+	Val V
+}
@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+
+	"example/concurrency/pipeline/seq"
+)
+
+func TestValuesBreakCancelsUpstream(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var calls atomic.Int64
+		next := counter(0)
+		var got []int
+		for v := range Values(context.Background(), func(ctx context.Context) <-chan int {
+			return Map(ctx, Repeat(ctx, func() int {
+				calls.Add(1)
+				return next()
+			}), sq)
+		}) {
+			got = append(got, v)
+			if len(got) == 3 {
+				break
+			}
+		}
+		if !slices.Equal(got, []int{0, 1, 4}) {
+			t.Fatalf("got %v, want [0 1 4]", got)
+		}
+		// break 后上游例程全部退出 (否则 synctest 报告死锁)，不再调用 fn
+		synctest.Wait()
+		n := calls.Load()
+		synctest.Wait()
+		if calls.Load() != n {
+			t.Fatal("Repeat kept running after break")
+		}
+	})
+}
+
+func TestValuesErr(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		build := func(ctx context.Context, g *Group) <-chan int {
+			in := Generate(ctx, 1, 2, 3, 4)
+			return MapErr(g, in, func(ctx context.Context, n int) (int, error) {
+				if n == 3 {
+					return 0, errOdd
+				}
+				return n * n, nil
+			})
+		}
+		var got []int
+		var err error
+		for v, e := range ValuesErr(context.Background(), build) {
+			if e != nil {
+				err = e
+				break
+			}
+			got = append(got, v)
+		}
+		if !errors.Is(err, errOdd) {
+			t.Fatalf("got err %v, want %v", err, errOdd)
+		}
+		if !slices.Equal(got, []int{1, 4}) {
+			t.Fatalf("got %v, want [1 4]", got)
+		}
+
+		// break 时停止 Group 并取消其 ctx，不产出错误；仍在发送的源随之退出
+		for v, e := range ValuesErr(context.Background(), func(ctx context.Context, g *Group) <-chan int {
+			return MapErr(g, Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8), sqErr)
+		}) {
+			if e != nil {
+				t.Fatalf("got err %v after break", e)
+			}
+			if v > 10 {
+				break
+			}
+		}
+		synctest.Wait() // 所有例程已退出，否则 synctest.Test 报告泄漏
+
+		// 循环体 panic 时同样停止 Group
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("the panic was not propagated")
+				}
+			}()
+			for range ValuesErr(context.Background(), func(ctx context.Context, g *Group) <-chan int {
+				return MapErr(g, Repeat(ctx, counter(0)), sqErr)
+			}) {
+				panic("boom")
+			}
+		}()
+		synctest.Wait()
+	})
+}
+
+func TestFromSeq(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		// 无限的同步迭代器在 ctx 结束时停止
+		out := Map(ctx, FromSeq(ctx, seq.Count(1)), sq)
+		got := []int{<-out, <-out, <-out}
+		cancel()
+		collect(out)
+		if !slices.Equal(got, []int{1, 4, 9}) {
+			t.Fatalf("got %v, want [1 4 9]", got)
+		}
+	})
+}
+
+func TestSeq2RoundTrip(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		m := map[string]int{"a": 1, "b": 2, "c": 3}
+		got := maps.Collect(Pairs(context.Background(), func(ctx context.Context) <-chan Pair[string, int] {
+			return Map(ctx, FromSeq2(ctx, maps.All(m)), func(p Pair[string, int]) Pair[string, int] {
+				return Pair[string, int]{p.Key, sq(p.Val)}
+			})
+		}))
+		if want := map[string]int{"a": 1, "b": 4, "c": 9}; !maps.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
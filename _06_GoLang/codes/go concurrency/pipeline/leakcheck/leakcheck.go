@@ -0,0 +1,141 @@
+/*
+! 包 leakcheck 检测测试结束后残留的例程
+- Check 在单个测试开始时记录例程快照，测试结束时检查新增且仍存活的例程
+- VerifyTestMain 在 TestMain 中包裹 m.Run，检查整个测试二进制的残留例程
+- 例程退出可能稍有延迟，因此检查会在 Timeout 内重试；使用 Check 的测试不应调用 t.Parallel
+*/
+package leakcheck
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Timeout 是等待例程退出的最长时间
+var Timeout = 2 * time.Second
+
+// Goroutine 是某一时刻的例程及其堆栈
+type Goroutine struct {
+	ID    uint64
+	State string
+	Stack string
+}
+
+func (g Goroutine) String() string {
+	return fmt.Sprintf("goroutine %d [%s]:\n%s", g.ID, g.State, g.Stack)
+}
+
+// Snapshot 返回当前所有例程
+func Snapshot() []Goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return parse(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// parse 解析 runtime.Stack 的输出，例程之间以空行分隔
+func parse(stacks []byte) []Goroutine {
+	var gs []Goroutine
+	for _, block := range bytes.Split(stacks, []byte("\n\n")) {
+		header, stack, _ := strings.Cut(string(block), "\n")
+		// header: goroutine 7 [chan receive]:
+		fields := strings.SplitN(strings.TrimSuffix(header, ":"), " ", 3)
+		if len(fields) < 3 || fields[0] != "goroutine" {
+			continue
+		}
+		id, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		state := strings.TrimSuffix(strings.TrimPrefix(fields[2], "["), "]")
+		gs = append(gs, Goroutine{ID: id, State: state, Stack: stack})
+	}
+	return gs
+}
+
+// currentID 返回调用者所在例程的 ID
+func currentID() uint64 {
+	buf := make([]byte, 64)
+	gs := parse(buf[:runtime.Stack(buf, false)])
+	if len(gs) == 0 {
+		return 0
+	}
+	return gs[0].ID
+}
+
+// Leaked 返回不在 base 中、且在 Timeout 内仍未退出的例程 (不含调用者自身)
+func Leaked(base []Goroutine) []Goroutine {
+	self := currentID()
+	known := make(map[uint64]bool, len(base))
+	for _, g := range base {
+		known[g.ID] = true
+	}
+	deadline := time.Now().Add(Timeout)
+	for wait := time.Millisecond; ; wait = min(2*wait, 100*time.Millisecond) {
+		var leaked []Goroutine
+		for _, g := range Snapshot() {
+			if !known[g.ID] && g.ID != self && !ignored(g) {
+				leaked = append(leaked, g)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(wait)
+	}
+}
+
+// ignored 过滤由测试框架或运行时创建的例程
+func ignored(g Goroutine) bool {
+	return slices.ContainsFunc([]string{
+		"testing.(*T).Run(",
+		"testing.(*M).Run(",
+		"testing.tRunner(",
+		"os/signal.signal_recv(",
+	}, func(fn string) bool { return strings.HasPrefix(g.Stack, fn) })
+}
+
+// Check 记录当前例程，并在 t 结束时检查是否有新增例程残留，残留时以其堆栈报告测试失败
+func Check(t testing.TB) {
+	t.Helper()
+	base := Snapshot()
+	t.Cleanup(func() {
+		if leaked := Leaked(base); len(leaked) > 0 {
+			t.Errorf("leakcheck: %d goroutine(s) leaked:\n\n%s", len(leaked), format(leaked))
+		}
+	})
+}
+
+// VerifyTestMain 运行 m.Run 并检查所有测试结束后残留的例程，然后以对应的退出码退出
+//
+//	func TestMain(m *testing.M) { leakcheck.VerifyTestMain(m) }
+func VerifyTestMain(m *testing.M) {
+	base := Snapshot()
+	code := m.Run()
+	if code == 0 {
+		if leaked := Leaked(base); len(leaked) > 0 {
+			fmt.Fprintf(os.Stderr, "leakcheck: %d goroutine(s) leaked:\n\n%s\n", len(leaked), format(leaked))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func format(gs []Goroutine) string {
+	s := make([]string, len(gs))
+	for i, g := range gs {
+		s[i] = g.String()
+	}
+	return strings.Join(s, "\n\n")
+}
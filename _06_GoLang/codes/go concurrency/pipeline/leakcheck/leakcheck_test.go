@@ -0,0 +1,62 @@
+package leakcheck
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) { VerifyTestMain(m) }
+
+func TestLeaked(t *testing.T) {
+	base := Snapshot()
+	block := make(chan struct{})
+	go func() { <-block }()
+	defer close(block)
+
+	Timeout = 10 * time.Millisecond
+	defer func() { Timeout = 2 * time.Second }()
+	leaked := Leaked(base)
+	if len(leaked) != 1 {
+		t.Fatalf("got %d leaked goroutines, want 1", len(leaked))
+	}
+	if leaked[0].State != "chan receive" || !strings.Contains(leaked[0].Stack, "TestLeaked") {
+		t.Fatalf("unexpected leaked goroutine:\n%v", leaked[0])
+	}
+}
+
+func TestLeakedWaitsForExit(t *testing.T) {
+	base := Snapshot()
+	go time.Sleep(20 * time.Millisecond)
+	if leaked := Leaked(base); len(leaked) != 0 {
+		t.Fatalf("goroutines that exit within Timeout should not be reported:\n%s", format(leaked))
+	}
+}
+
+// fakeTB 记录 Check 报告的错误
+type fakeTB struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (f *fakeTB) Helper()                   {}
+func (f *fakeTB) Cleanup(fn func())         { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeTB) Errorf(s string, a ...any) { f.errors = append(f.errors, s) }
+
+func TestCheck(t *testing.T) {
+	Timeout = 10 * time.Millisecond
+	defer func() { Timeout = 2 * time.Second }()
+
+	tb := &fakeTB{}
+	Check(tb)
+	block := make(chan struct{})
+	go func() { <-block }()
+	tb.cleanups[0]()
+	close(block)
+	if len(tb.errors) != 1 {
+		t.Fatalf("Check should report the leaked goroutine, got %d errors", len(tb.errors))
+	}
+
+	Check(t)
+}
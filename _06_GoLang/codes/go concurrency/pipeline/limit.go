@@ -0,0 +1,229 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter 限制事件发生的速率，Wait 阻塞到允许下一个事件或 ctx 结束
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket 是令牌桶限流器：每 every 补充一个令牌，最多积累 burst 个令牌，
+// 因此空闲后允许最多 burst 个事件突发通过
+type TokenBucket struct {
+	every time.Duration
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 返回初始装满 burst 个令牌的令牌桶
+func NewTokenBucket(every time.Duration, burst int) (*TokenBucket, error) {
+	if every <= 0 || burst < 1 {
+		return nil, fmt.Errorf("pipeline: invalid token bucket (every %v, burst %d)", every, burst)
+	}
+	return &TokenBucket{every: every, burst: float64(burst), tokens: float64(burst), last: time.Now()}, nil
+}
+
+// refill 按流逝的时间补充令牌，调用者需持有 b.mu
+func (b *TokenBucket) refill(now time.Time) {
+	b.tokens = min(b.burst, b.tokens+float64(now.Sub(b.last))/float64(b.every))
+	b.last = now
+}
+
+// Allow 在有可用令牌时消耗一个令牌并返回 true，否则立即返回 false
+func (b *TokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Wait 预订一个令牌并等待其可用；ctx 先结束时归还预订并返回 ctx.Err()
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	b.refill(time.Now())
+	b.tokens--
+	wait := time.Duration(-b.tokens * float64(b.every))
+	b.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Throttle 在转发 in 中的每个值之前等待 lim，等待 lim 的时长计为处理耗时
+func Throttle[T any](ctx context.Context, in <-chan T, lim Limiter, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	out := make(chan T)
+	go func() {
+		defer c.exited()
+		defer close(out)
+		pr := c.newProbe("throttle")
+		l := c.newStageLog("throttle", c.worker)
+		defer l.lifecycle(ctx)()
+		waiting := pr.now()
+		for {
+			var v T
+			select {
+			case <-ctx.Done():
+				return
+			case x, ok := <-in:
+				if !ok {
+					return
+				}
+				v = x
+			}
+			probeReceived(pr, waiting, in)
+			l.received()
+			start := pr.now()
+			if lim.Wait(ctx) != nil {
+				return
+			}
+			pr.processed(start)
+			sending := pr.now()
+			if Send(ctx, out, v) != nil {
+				return
+			}
+			pr.sent(sending)
+			l.sent()
+			waiting = pr.now()
+		}
+	}()
+	return out
+}
+
+// ThrottleByKey 按 key(v) 分别限流：每个键由 newLimiter 创建独立的 Limiter 并在独立的例程中等待，
+// 每个键可缓冲 1 个待放行的值，只有某个键积压超过该值时才会阻塞其他键。
+// 同一键内保持输入顺序，不同键之间不保证顺序；键的数量应当有界
+func ThrottleByKey[T any, K comparable](ctx context.Context, in <-chan T, key func(T) K, newLimiter func(K) Limiter, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	pr := c.newProbe("throttle_by_key")
+	l := c.newStageLog("throttle_by_key", c.worker)
+	var wg sync.WaitGroup
+	out := make(chan T)
+	go func() {
+		exit := l.lifecycle(ctx)
+		lanes := make(map[K]chan T)
+		defer func() {
+			for _, lane := range lanes {
+				close(lane)
+			}
+			wg.Wait()
+			exit()
+			close(out)
+		}()
+		waiting := pr.now()
+		for {
+			var v T
+			select {
+			case <-ctx.Done():
+				return
+			case x, ok := <-in:
+				if !ok {
+					return
+				}
+				v = x
+			}
+			probeReceived(pr, waiting, in)
+			l.received()
+			k := key(v)
+			lane, ok := lanes[k]
+			if !ok {
+				lane = make(chan T, 1)
+				lanes[k] = lane
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for v := range Throttle(ctx, lane, newLimiter(k)) {
+						sending := pr.now()
+						if Send(ctx, out, v) != nil {
+							return
+						}
+						pr.sent(sending)
+						l.sent()
+					}
+				}()
+			}
+			if Send(ctx, lane, v) != nil {
+				return
+			}
+			waiting = pr.now()
+		}
+	}()
+	return out
+}
+
+// Leak 是漏桶阶段：in 中的值先进入容量为 capacity 的桶，再以每 every 一个的恒定速率流出。
+// capacity 决定可吸收的上游突发量；桶满时阻塞上游 (背压) 而不丢弃数据。
+// 度量中输入通道的占用即桶的占用
+func Leak[T any](ctx context.Context, in <-chan T, every time.Duration, capacity int, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	bucket := make(chan T, max(capacity, 0))
+	go func() {
+		defer close(bucket)
+		for v := range OrDone(ctx, in) {
+			if Send(ctx, bucket, v) != nil {
+				return
+			}
+		}
+	}()
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("leak")
+		l := c.newStageLog("leak", c.worker)
+		defer l.lifecycle(ctx)()
+		timer := time.NewTimer(every)
+		defer timer.Stop()
+		waiting := pr.now()
+		for {
+			var v T
+			select {
+			case <-ctx.Done():
+				return
+			case x, ok := <-bucket:
+				if !ok {
+					return
+				}
+				v = x
+			}
+			probeReceived(pr, waiting, bucket)
+			l.received()
+			sending := pr.now()
+			if Send(ctx, out, v) != nil {
+				return
+			}
+			pr.sent(sending)
+			l.sent()
+			timer.Reset(every)
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			waiting = pr.now()
+		}
+	}()
+	return out
+}
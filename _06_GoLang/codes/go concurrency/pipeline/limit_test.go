@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+const ms = time.Millisecond
+
+// timestamps 记录 in 中每个值到达的 (虚拟) 时间相对 start 的偏移
+func timestamps[T any](start time.Time, in <-chan T) []time.Duration {
+	var rt []time.Duration
+	for range in {
+		rt = append(rt, time.Since(start))
+	}
+	return rt
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		start := time.Now()
+		b, err := NewTokenBucket(10*time.Millisecond, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := timestamps(start, Throttle(ctx, Generate(ctx, 1, 2, 3, 4, 5, 6), b))
+		// 前 3 个作为突发立即通过，之后每 10ms 一个
+		want := []time.Duration{0, 0, 0, 10 * ms, 20 * ms, 30 * ms}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestTokenBucketAllow(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		b, err := NewTokenBucket(time.Second, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !b.Allow() || !b.Allow() || b.Allow() {
+			t.Fatal("Allow should admit exactly burst events")
+		}
+		time.Sleep(time.Second)
+		if !b.Allow() {
+			t.Fatal("Allow should admit an event after one refill interval")
+		}
+		if _, err := NewTokenBucket(0, 1); err == nil {
+			t.Fatal("expected error for zero interval")
+		}
+	})
+}
+
+func TestTokenBucketWaitCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		b, err := NewTokenBucket(time.Second, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Allow()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got err %v, want %v", err, context.DeadlineExceeded)
+		}
+		// 取消的预订被归还，令牌在最初的 1s 时可用
+		start := time.Now()
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed != 900*time.Millisecond {
+			t.Fatalf("waited %v, want 900ms", elapsed)
+		}
+	})
+}
+
+func TestThrottleByKey(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		start := time.Now()
+		type req struct {
+			user string
+			at   time.Duration
+		}
+		newLimiter := func(string) Limiter {
+			b, _ := NewTokenBucket(10*time.Millisecond, 1)
+			return b
+		}
+		in := Generate(ctx, "a", "a", "b", "a", "b")
+		out := Map(ctx, ThrottleByKey(ctx, in, func(s string) string { return s }, newLimiter), func(s string) req {
+			return req{s, time.Since(start)}
+		})
+		got := collect(out)
+		byUser := map[string][]time.Duration{}
+		for _, r := range got {
+			byUser[r.user] = append(byUser[r.user], r.at)
+		}
+		if want := []time.Duration{0, 10 * ms, 20 * ms}; !slices.Equal(byUser["a"], want) {
+			t.Fatalf("user a: got %v, want %v", byUser["a"], want)
+		}
+		// b 不受 a 的限流影响
+		if want := []time.Duration{0, 10 * ms}; !slices.Equal(byUser["b"], want) {
+			t.Fatalf("user b: got %v, want %v", byUser["b"], want)
+		}
+	})
+}
+
+func TestLeak(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		start := time.Now()
+		got := timestamps(start, Leak(ctx, Generate(ctx, 1, 2, 3, 4), 5*time.Millisecond, 2))
+		want := []time.Duration{0, 5 * ms, 10 * ms, 15 * ms}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestLeakCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := Leak(ctx, Repeat(ctx, counter(0)), time.Hour, 4)
+		<-out
+		cancel()
+		for range out {
+		}
+		synctest.Wait()
+	})
+}
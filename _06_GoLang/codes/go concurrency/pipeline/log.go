@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+)
+
+// 生命周期事件的日志消息
+const (
+	MsgStart  = "stage start"  // 例程启动 (含重启)
+	MsgStop   = "stage stop"   // 输入耗尽后正常退出
+	MsgCancel = "stage cancel" // 因 ctx 结束退出
+	MsgError  = "stage error"  // 因错误或中止的 panic 退出
+	MsgPanic  = "stage panic"  // 恢复了一次 panic
+)
+
+// WithLogger 使阶段通过 logger 记录生命周期事件 (MsgStart 等)，
+// 记录带有属性 stage、worker、in 与 out (已收到与已发出的项数)；所有接受 opts 的阶段都支持该选项
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) { c.logger = logger }
+}
+
+// WithWorkerID 设置记录在日志中的 worker 编号，FanOutErr 等多 worker 阶段会自动设置
+func WithWorkerID(id int) Option {
+	return func(c *config) { c.worker = id }
+}
+
+// withWorker 返回追加了 WithWorkerID(id) 的 opts 副本
+func withWorker(opts []Option, id int) []Option {
+	return append(opts[:len(opts):len(opts)], WithWorkerID(id))
+}
+
+// stageLog 记录一个阶段例程的生命周期事件与项数，nil 表示未启用日志。
+// 由多个例程组成的阶段 (如 FanInWith) 共用一个 stageLog，项数可被并发累加
+type stageLog struct {
+	logger  *slog.Logger
+	stage   string
+	worker  int
+	attrs   []slog.Attr // 附加在每条记录上的属性，如监督者子例程的 child
+	in, out atomic.Int64
+}
+
+func (c *config) newStageLog(def string, worker int) *stageLog {
+	if c.logger == nil {
+		return nil
+	}
+	return &stageLog{logger: c.logger, stage: c.stageName(def), worker: worker}
+}
+
+func (l *stageLog) received() {
+	if l != nil {
+		l.in.Add(1)
+	}
+}
+
+func (l *stageLog) sent() {
+	if l != nil {
+		l.out.Add(1)
+	}
+}
+
+func (l *stageLog) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	attrs = slices.Concat([]slog.Attr{
+		slog.String("stage", l.stage),
+		slog.Int("worker", l.worker),
+		slog.Int64("in", l.in.Load()),
+		slog.Int64("out", l.out.Load()),
+	}, l.attrs, attrs)
+	l.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+func (l *stageLog) start(restarts int) {
+	if l == nil {
+		return
+	}
+	if restarts > 0 {
+		l.log(slog.LevelInfo, MsgStart, slog.Int("restart", restarts))
+		return
+	}
+	l.log(slog.LevelInfo, MsgStart)
+}
+
+// panicked 记录恢复的 panic，attrs 说明随后的处理 (如 policy)
+func (l *stageLog) panicked(perr *PanicError, attrs ...slog.Attr) {
+	if l != nil {
+		l.log(slog.LevelError, MsgPanic, append([]slog.Attr{slog.Any("panic", perr.Value)}, attrs...)...)
+	}
+}
+
+// exit 按 err 记录例程的退出：nil 为 MsgStop，ctx 结束为 MsgCancel，其余为 MsgError
+func (l *stageLog) exit(err error) {
+	if l == nil {
+		return
+	}
+	var perr *PanicError
+	switch {
+	case err == nil:
+		l.log(slog.LevelInfo, MsgStop)
+	case errors.As(err, &perr):
+		l.log(slog.LevelError, MsgError, slog.Any("panic", perr.Value))
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		l.log(slog.LevelInfo, MsgCancel, slog.Any("cause", err))
+	default:
+		l.log(slog.LevelError, MsgError, slog.Any("error", err))
+	}
+}
+
+// lifecycle 为不经 spawn 启动的阶段记录启动，返回记录退出的函数：ctx 已结束时为 MsgCancel，否则为 MsgStop。
+// 惯用法为 defer l.lifecycle(ctx)()
+func (l *stageLog) lifecycle(ctx context.Context) (exit func()) {
+	l.start(0)
+	return func() { l.exit(ctx.Err()) }
+}
@@ -0,0 +1,378 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/synctest"
+)
+
+// recorder 以 JSON 记录日志，并像 slogtest 的 result 函数一样将每行解析为 map
+type recorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+func (r *recorder) logger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(r, nil))
+}
+
+func (r *recorder) records(t *testing.T) []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ms []map[string]any
+	for _, line := range bytes.Split(r.buf.Bytes(), []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatal(err)
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// messages 返回各记录的消息
+func messages(ms []map[string]any) []string {
+	var rt []string
+	for _, m := range ms {
+		rt = append(rt, m[slog.MessageKey].(string))
+	}
+	return rt
+}
+
+func TestMapLogsLifecycle(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var rec recorder
+		collect(Map(ctx, Generate(ctx, 1, 2, 3), sq, WithName("sq"), WithLogger(rec.logger())))
+		synctest.Wait()
+
+		ms := rec.records(t)
+		if got := messages(ms); !slices.Equal(got, []string{MsgStart, MsgStop}) {
+			t.Fatalf("got messages %v, want [%s %s]", got, MsgStart, MsgStop)
+		}
+		stop := ms[1]
+		// JSON 数字解析为 float64
+		for k, want := range map[string]any{"stage": "sq", "worker": 0.0, "in": 3.0, "out": 3.0, "level": "INFO"} {
+			if stop[k] != want {
+				t.Errorf("stop record %s = %v, want %v", k, stop[k], want)
+			}
+		}
+	})
+}
+
+func TestRepeatLogsCancel(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var rec recorder
+		out := Repeat(ctx, counter(0), WithLogger(rec.logger()))
+		<-out
+		<-out
+		cancel()
+		collect(out)
+
+		ms := rec.records(t)
+		if got := messages(ms); !slices.Equal(got, []string{MsgStart, MsgCancel}) {
+			t.Fatalf("got messages %v, want [%s %s]", got, MsgStart, MsgCancel)
+		}
+		if ms[1]["stage"] != "repeat" || ms[1]["cause"] != context.Canceled.Error() {
+			t.Fatalf("unexpected cancel record %v", ms[1])
+		}
+		if out := ms[1]["out"].(float64); out < 2 {
+			t.Fatalf("got out %v, want at least 2", out)
+		}
+	})
+}
+
+func TestMapLogsPanicRestart(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var rec recorder
+		collect(Map(ctx, Generate(ctx, 1, 2, 3, 4), panicOn3,
+			WithPanicPolicy(RestartWorker), WithLogger(rec.logger())))
+
+		ms := rec.records(t)
+		want := []string{MsgStart, MsgPanic, MsgStart, MsgStop}
+		if got := messages(ms); !slices.Equal(got, want) {
+			t.Fatalf("got messages %v, want %v", got, want)
+		}
+		if p := ms[1]; p["level"] != "ERROR" || p["policy"] != "restart" || p["panic"] == nil || p["in"] != 3.0 {
+			t.Fatalf("unexpected panic record %v", p)
+		}
+		if ms[2]["restart"] != 1.0 {
+			t.Fatalf("got restart %v, want 1", ms[2]["restart"])
+		}
+		if ms[3]["in"] != 4.0 || ms[3]["out"] != 3.0 {
+			t.Fatalf("got in %v out %v, want 4 3", ms[3]["in"], ms[3]["out"])
+		}
+	})
+}
+
+func TestFanOutErrLogsWorkers(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		var rec recorder
+		fn := func(ctx context.Context, n int) (int, error) {
+			if n == 5 {
+				return 0, errOdd
+			}
+			return n, nil
+		}
+		outs, err := FanOutErr(g, 2, Generate(ctx, 1, 2, 3, 4, 5, 6), fn, WithName("check"), WithLogger(rec.logger()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		collect(FanIn(ctx, outs...))
+		if err := g.Wait(); !errors.Is(err, errOdd) {
+			t.Fatalf("got err %v, want %v", err, errOdd)
+		}
+
+		workers := map[float64]bool{}
+		var errs, others int
+		for _, m := range rec.records(t) {
+			workers[m["worker"].(float64)] = true
+			switch m[slog.MessageKey] {
+			case MsgError:
+				errs++
+				if m["error"] != errOdd.Error() || m["level"] != "ERROR" {
+					t.Errorf("unexpected error record %v", m)
+				}
+			case MsgCancel, MsgStop:
+				// 另一个 worker 因取消退出，或在取消前已读到输入关闭
+				others++
+			}
+		}
+		if len(workers) != 2 || !workers[0] || !workers[1] {
+			t.Fatalf("got workers %v, want 0 and 1", workers)
+		}
+		if errs != 1 || others != 1 {
+			t.Fatalf("got %d error and %d other exit records, want 1 and 1", errs, others)
+		}
+	})
+}
+
+func TestStagesLogLifecycle(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var r recorder
+		log := WithLogger(r.logger())
+		values := make([]int, 10) // 多于 Take 取走的项，两个源都无法发送完毕
+		a := GenerateWith(ctx, values, WithName("a"), log)
+		b := GenerateWith(ctx, values, WithName("b"), log)
+		got := collect(Take(ctx, FanInWith(ctx, []<-chan int{a, b}, log), 2, log))
+		if len(got) != 2 {
+			t.Fatalf("got %v, want 2 items", got)
+		}
+		cancel()
+		synctest.Wait()
+
+		// 每个阶段记录一次启动与一次退出：Take 取满后正常退出，其余阶段因 ctx 结束退出
+		want := map[string][]string{
+			"a":      {MsgStart, MsgCancel},
+			"b":      {MsgStart, MsgCancel},
+			"fan_in": {MsgStart, MsgCancel},
+			"take":   {MsgStart, MsgStop},
+		}
+		byStage := make(map[string][]map[string]any)
+		for _, m := range r.records(t) {
+			stage := m["stage"].(string)
+			byStage[stage] = append(byStage[stage], m)
+		}
+		for stage, msgs := range want {
+			if got := messages(byStage[stage]); !slices.Equal(got, msgs) {
+				t.Errorf("%s: got %v, want %v", stage, got, msgs)
+			}
+		}
+		if last := byStage["take"][1]; last["in"] != 2.0 || last["out"] != 2.0 {
+			t.Errorf("take: got in %v out %v, want 2 2", last["in"], last["out"])
+		}
+	})
+}
+
+// byStage 按 stage、worker 与 child 分组记录，键如 "stage/1" 与 "stage/1/child"
+func byStage(ms []map[string]any) map[string][]map[string]any {
+	groups := make(map[string][]map[string]any)
+	for _, m := range ms {
+		key := m["stage"].(string)
+		if w := m["worker"].(float64); w != 0 {
+			key += "/" + strconv.Itoa(int(w))
+		}
+		if c, ok := m["child"].(string); ok {
+			key += "/" + c
+		}
+		groups[key] = append(groups[key], m)
+	}
+	return groups
+}
+
+func TestSupervisorLogsRestarts(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var rec recorder
+		runs := 0
+		flaky := Child{Name: "flaky", Restart: Transient, Run: func(ctx context.Context) error {
+			if runs++; runs == 1 {
+				panic("boom")
+			}
+			return nil
+		}}
+		sup := NewSupervisorWith(SupervisorSpec{}, []Child{flaky}, WithName("sup"), WithLogger(rec.logger()))
+		if err := sup.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// 监督者自身的记录没有 child 属性；子例程的 panic 后跟随带 restart 的 MsgStart
+		var own, child []map[string]any
+		for _, m := range rec.records(t) {
+			if m["stage"] != "sup" {
+				t.Fatalf("unexpected record %v", m)
+			}
+			if m["child"] == "flaky" {
+				child = append(child, m)
+			} else {
+				own = append(own, m)
+			}
+		}
+		if got := messages(own); !slices.Equal(got, []string{MsgStart, MsgStop}) {
+			t.Errorf("supervisor: got %v, want [%s %s]", got, MsgStart, MsgStop)
+		}
+		want := []string{MsgStart, MsgPanic, MsgError, MsgStart, MsgStop}
+		if got := messages(child); !slices.Equal(got, want) {
+			t.Fatalf("child: got %v, want %v", got, want)
+		}
+		if child[1]["panic"] != "boom" || child[3]["restart"] != 1.0 {
+			t.Errorf("got panic %v and restart %v, want boom and 1", child[1]["panic"], child[3]["restart"])
+		}
+	})
+}
+
+func TestFanOutSupervisedLogsItems(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var rec recorder
+		g, ctx := WithContext(context.Background())
+		outs, err := FanOutSupervised(g, SupervisorSpec{}, 2, Generate(ctx, 1, 2, 3, 4), sqErr, WithLogger(rec.logger()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		collect(FanIn(ctx, outs...))
+		if err := g.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		var in float64
+		for key, ms := range byStage(rec.records(t)) {
+			if got := messages(ms); !slices.Equal(got, []string{MsgStart, MsgStop}) {
+				t.Errorf("%s: got %v, want [%s %s]", key, got, MsgStart, MsgStop)
+			}
+			if ms[0]["child"] != nil {
+				in += ms[1]["in"].(float64)
+			}
+		}
+		if in != 4 {
+			t.Fatalf("workers received %v items, want 4", in)
+		}
+	})
+}
+
+func TestStewardLogsRestart(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var rec recorder
+		starts := 0
+		out := Steward(ctx, 50*ms, func(ctx context.Context, hb chan<- Heartbeat) <-chan int {
+			if starts++; starts == 1 {
+				return make(chan int) // 卡住：没有心跳也没有输出
+			}
+			return Repeat(ctx, counter(0), WithHeartbeat(hb, 10*ms))
+		}, WithLogger(rec.logger()))
+		<-out
+		cancel()
+		collect(out)
+
+		ms := rec.records(t)
+		if got, want := messages(ms), []string{MsgStart, MsgStart, MsgCancel}; !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if ms[1]["stage"] != "steward" || ms[1]["restart"] != 1.0 {
+			t.Fatalf("unexpected restart record %v", ms[1])
+		}
+	})
+}
+
+func TestBrokerLogsLifecycle(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var rec recorder
+		b := NewBroker[int](context.Background(), WithLogger(rec.logger()))
+		s, err := b.Subscribe("t", 2, Block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Publish(context.Background(), "t", 1)
+		b.Publish(context.Background(), "t", 2)
+		b.Publish(context.Background(), "none", 3)
+		b.Close()
+		collect(s.C())
+		synctest.Wait()
+
+		ms := rec.records(t)
+		if got := messages(ms); !slices.Equal(got, []string{MsgStart, MsgStop}) {
+			t.Fatalf("got %v, want [%s %s]", got, MsgStart, MsgStop)
+		}
+		if ms[1]["stage"] != "broker" || ms[1]["in"] != 3.0 || ms[1]["out"] != 2.0 {
+			t.Fatalf("unexpected stop record %v", ms[1])
+		}
+	})
+}
+
+func TestFanOutStealingLogsWorkers(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var rec recorder
+		log := WithLogger(rec.logger())
+		b := NewBreaker(BreakerConfig{ConsecutiveFailures: 1})
+		work := BreakerWork(b, func(ctx context.Context, in <-chan int) <-chan int {
+			return Map(ctx, in, sq)
+		}, func(int) bool { return false }, nil, log)
+		outs, err := FanOutStealing(ctx, 2, Generate(ctx, 1, 2, 3, 4), work, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := collect(FanIn(ctx, outs...)); len(got) != 4 {
+			t.Fatalf("got %v, want 4 items", got)
+		}
+		synctest.Wait()
+
+		// 两个阶段各有 worker 0 与 1，每个 worker 记录一次启动与一次退出
+		sent := map[string]float64{}
+		groups := byStage(rec.records(t))
+		for _, key := range []string{"fan_out_stealing", "fan_out_stealing/1", "breaker_work", "breaker_work/1"} {
+			ms := groups[key]
+			if got := messages(ms); !slices.Equal(got, []string{MsgStart, MsgStop}) {
+				t.Fatalf("%s: got %v, want [%s %s]", key, got, MsgStart, MsgStop)
+			}
+			stage, _, _ := strings.Cut(key, "/")
+			sent[stage] += ms[1]["out"].(float64)
+		}
+		if sent["fan_out_stealing"] != 4 || sent["breaker_work"] != 4 {
+			t.Fatalf("got sent counts %v, want 4 for each stage", sent)
+		}
+	})
+}
@@ -0,0 +1,9 @@
+package pipeline
+
+import (
+	"testing"
+
+	"example/concurrency/pipeline/leakcheck"
+)
+
+func TestMain(m *testing.M) { leakcheck.VerifyTestMain(m) }
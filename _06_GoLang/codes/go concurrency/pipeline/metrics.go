@@ -0,0 +1,220 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Instrument 接收阶段的度量事件，stage 为 WithName 设置的阶段名；实现必须可被多个例程并发调用
+type Instrument interface {
+	ItemIn(stage string)                          // 从上游收到一项
+	ItemOut(stage string)                         // 向下游发出一项
+	Processed(stage string, d time.Duration)      // 处理一项的耗时
+	BlockedRecv(stage string, d time.Duration)    // 等待上游的时长
+	BlockedSend(stage string, d time.Duration)    // 等待下游的时长
+	Occupancy(stage string, length, capacity int) // 收到一项时输入通道的占用
+}
+
+// WithName 设置阶段名，用于度量与日志；未设置时使用阶段函数名 (如 "map")
+func WithName(name string) Option {
+	return func(c *config) { c.name = name }
+}
+
+// WithInstrument 使阶段向 inst 报告度量事件
+func WithInstrument(inst Instrument) Option {
+	return func(c *config) { c.instrument = inst }
+}
+
+// stageName 返回阶段名，未设置时返回 def
+func (c *config) stageName(def string) string {
+	if c.name == "" {
+		return def
+	}
+	return c.name
+}
+
+// probe 为一个阶段例程报告度量事件，nil 表示未启用度量
+type probe struct {
+	inst  Instrument
+	stage string
+}
+
+func (c *config) newProbe(def string) *probe {
+	if c.instrument == nil {
+		return nil
+	}
+	return &probe{inst: c.instrument, stage: c.stageName(def)}
+}
+
+// now 在启用度量时返回当前时间，避免未启用时的计时开销
+func (p *probe) now() time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func probeReceived[T any](p *probe, since time.Time, in <-chan T) {
+	if p == nil {
+		return
+	}
+	p.inst.BlockedRecv(p.stage, time.Since(since))
+	p.inst.ItemIn(p.stage)
+	p.inst.Occupancy(p.stage, len(in), cap(in))
+}
+
+func (p *probe) processed(since time.Time) {
+	if p != nil {
+		p.inst.Processed(p.stage, time.Since(since))
+	}
+}
+
+func (p *probe) sent(since time.Time) {
+	if p != nil {
+		p.inst.BlockedSend(p.stage, time.Since(since))
+		p.inst.ItemOut(p.stage)
+	}
+}
+
+// DurationBuckets 是处理耗时直方图的桶上界 (与 Prometheus 客户端的默认桶相同)
+var DurationBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// StageMetrics 是一个阶段的累计度量
+type StageMetrics struct {
+	In, Out     int64
+	Busy        time.Duration // 处理耗时总和
+	BlockedRecv time.Duration
+	BlockedSend time.Duration
+	Buckets     []int64 // 各桶内 (非累计) 的处理次数，最后一项为超出最大上界的次数
+	Length      int     // 最近一次观测到的输入通道长度
+	Capacity    int
+}
+
+// Collector 是内存中的 Instrument 实现，可导出为 Prometheus 文本格式
+type Collector struct {
+	mu     sync.Mutex
+	stages map[string]*StageMetrics
+}
+
+// NewCollector 返回空的 Collector
+func NewCollector() *Collector {
+	return &Collector{stages: make(map[string]*StageMetrics)}
+}
+
+func (c *Collector) update(stage string, fn func(m *StageMetrics)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.stages[stage]
+	if !ok {
+		m = &StageMetrics{Buckets: make([]int64, len(DurationBuckets)+1)}
+		c.stages[stage] = m
+	}
+	fn(m)
+}
+
+func (c *Collector) ItemIn(stage string)  { c.update(stage, func(m *StageMetrics) { m.In++ }) }
+func (c *Collector) ItemOut(stage string) { c.update(stage, func(m *StageMetrics) { m.Out++ }) }
+
+func (c *Collector) Processed(stage string, d time.Duration) {
+	c.update(stage, func(m *StageMetrics) {
+		m.Busy += d
+		i, _ := slices.BinarySearch(DurationBuckets, d)
+		m.Buckets[i]++
+	})
+}
+
+func (c *Collector) BlockedRecv(stage string, d time.Duration) {
+	c.update(stage, func(m *StageMetrics) { m.BlockedRecv += d })
+}
+
+func (c *Collector) BlockedSend(stage string, d time.Duration) {
+	c.update(stage, func(m *StageMetrics) { m.BlockedSend += d })
+}
+
+func (c *Collector) Occupancy(stage string, length, capacity int) {
+	c.update(stage, func(m *StageMetrics) { m.Length, m.Capacity = length, capacity })
+}
+
+// Snapshot 返回各阶段度量的副本
+func (c *Collector) Snapshot() map[string]StageMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rt := make(map[string]StageMetrics, len(c.stages))
+	for name, m := range c.stages {
+		cp := *m
+		cp.Buckets = slices.Clone(m.Buckets)
+		rt[name] = cp
+	}
+	return rt
+}
+
+// WritePrometheus 以 Prometheus 文本格式 (0.0.4) 写出所有阶段的度量，阶段按名称排序
+func (c *Collector) WritePrometheus(w io.Writer) error {
+	snap := c.Snapshot()
+	names := slices.Sorted(maps.Keys(snap))
+	bw := bufio.NewWriter(w)
+
+	family := func(name, typ, help string, value func(m StageMetrics) string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, stage := range names {
+			fmt.Fprintf(bw, "%s{stage=\"%s\"} %s\n", name, escapeLabel(stage), value(snap[stage]))
+		}
+	}
+	count := func(n int64) string { return strconv.FormatInt(n, 10) }
+	seconds := func(d time.Duration) string { return formatFloat(d.Seconds()) }
+
+	family("pipeline_items_in_total", "counter", "Items received by the stage.",
+		func(m StageMetrics) string { return count(m.In) })
+	family("pipeline_items_out_total", "counter", "Items sent downstream by the stage.",
+		func(m StageMetrics) string { return count(m.Out) })
+	family("pipeline_recv_blocked_seconds_total", "counter", "Time spent waiting for upstream.",
+		func(m StageMetrics) string { return seconds(m.BlockedRecv) })
+	family("pipeline_send_blocked_seconds_total", "counter", "Time spent waiting for downstream.",
+		func(m StageMetrics) string { return seconds(m.BlockedSend) })
+	family("pipeline_channel_length", "gauge", "Items buffered in the input channel.",
+		func(m StageMetrics) string { return strconv.Itoa(m.Length) })
+	family("pipeline_channel_capacity", "gauge", "Capacity of the input channel.",
+		func(m StageMetrics) string { return strconv.Itoa(m.Capacity) })
+
+	const hist = "pipeline_item_duration_seconds"
+	fmt.Fprintf(bw, "# HELP %s Time spent processing one item.\n# TYPE %s histogram\n", hist, hist)
+	for _, stage := range names {
+		m, label := snap[stage], escapeLabel(stage)
+		var cum int64
+		for i, n := range m.Buckets {
+			cum += n
+			le := "+Inf"
+			if i < len(DurationBuckets) {
+				le = formatFloat(DurationBuckets[i].Seconds())
+			}
+			fmt.Fprintf(bw, "%s_bucket{stage=\"%s\",le=\"%s\"} %d\n", hist, label, le, cum)
+		}
+		fmt.Fprintf(bw, "%s_sum{stage=\"%s\"} %s\n", hist, label, seconds(m.Busy))
+		fmt.Fprintf(bw, "%s_count{stage=\"%s\"} %d\n", hist, label, cum)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP 以 Prometheus 文本格式响应抓取请求，可直接挂载到 /metrics
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WritePrometheus(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatFloat(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
@@ -0,0 +1,187 @@
+package pipeline
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestMapInstrument(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		col := NewCollector()
+		in := make(chan int, 4)
+		slow := func(n int) int {
+			time.Sleep(20 * ms)
+			return n * n
+		}
+		out := Map(ctx, in, slow, WithName("slow_sq"), WithInstrument(col))
+
+		// 上游 10ms 后一次送入 3 项；下游每 50ms 读取一项
+		time.Sleep(10 * ms)
+		in <- 1
+		in <- 2
+		in <- 3
+		close(in)
+		var got []int
+		for {
+			time.Sleep(50 * ms)
+			v, ok := <-out
+			if !ok {
+				break
+			}
+			got = append(got, v)
+		}
+		if !slices.Equal(got, []int{1, 4, 9}) {
+			t.Fatalf("got %v, want [1 4 9]", got)
+		}
+
+		m := col.Snapshot()["slow_sq"]
+		if m.In != 3 || m.Out != 3 {
+			t.Fatalf("got in %d out %d, want 3 3", m.In, m.Out)
+		}
+		if m.Busy != 60*ms {
+			t.Fatalf("got busy %v, want 60ms", m.Busy)
+		}
+		// 第 1 项等待上游 10ms，之后的项在下游读取时已缓冲在输入通道中
+		if m.BlockedRecv != 10*ms {
+			t.Fatalf("got blocked recv %v, want 10ms", m.BlockedRecv)
+		}
+		// 每项处理完后还需等待下游 30ms (如第 1 项在 30ms 发出，下游于 60ms 读取)
+		if m.BlockedSend != 90*ms {
+			t.Fatalf("got blocked send %v, want 90ms", m.BlockedSend)
+		}
+		if m.Length != 0 || m.Capacity != 4 {
+			t.Fatalf("got occupancy %d/%d, want 0/4", m.Length, m.Capacity)
+		}
+		// 每项耗时 20ms，落在 25ms 桶中
+		if m.Buckets[2] != 3 {
+			t.Fatalf("got buckets %v, want 3 items in the 25ms bucket", m.Buckets)
+		}
+	})
+}
+
+func TestPoolInstrument(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		col := NewCollector()
+		hb := make(chan Heartbeat, 10)
+		p, err := NewPool(ctx, 2, 4, Generate(ctx, 1, 2, 3), sq, WithInstrument(col), WithHeartbeat(hb, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		collect(p.Out())
+		m, ok := col.Snapshot()["pool"]
+		if !ok || m.In != 3 || m.Out != 3 || m.Capacity != 4 {
+			t.Fatalf("got %+v, want 3 items in and out through a queue of 4", m)
+		}
+		if n := len(hb); n != 3 {
+			t.Fatalf("got %d heartbeats, want one per item", n)
+		}
+	})
+}
+
+// 不调用用户函数的阶段同样报告收发的项数
+func TestStageInstrument(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		col := NewCollector()
+		opts := func(name string) []Option { return []Option{WithName(name), WithInstrument(col)} }
+		src := GenerateWith(ctx, []int{1, 2, 3, 4, 5}, opts("gen")...)
+		outs := TeeWith(ctx, Take(ctx, src, 4, opts("take")...), []Branch{{}, {}}, opts("tee")...)
+		merged := FanInWith(ctx, outs, opts("merge")...)
+		got := collect(BatchCount(ctx, merged, 3, opts("batch")...))
+		if len(got) != 3 {
+			t.Fatalf("got %d batches, want 3", len(got))
+		}
+		snap := col.Snapshot()
+		for name, want := range map[string][2]int64{
+			"gen":   {0, 4}, // Take 取满 4 项后不再读取
+			"take":  {4, 4},
+			"tee":   {4, 4},
+			"merge": {8, 8},
+			"batch": {8, 3},
+		} {
+			if m := snap[name]; m.In != want[0] || m.Out != want[1] {
+				t.Errorf("%s: got in %d out %d, want %d %d", name, m.In, m.Out, want[0], want[1])
+			}
+		}
+	})
+}
+
+func TestWritePrometheus(t *testing.T) {
+	col := NewCollector()
+	col.ItemIn("sq")
+	col.ItemOut("sq")
+	col.Processed("sq", 5*time.Millisecond)
+	col.Processed("sq", 2*time.Second)
+	col.BlockedRecv("sq", 1500*time.Millisecond)
+	col.Occupancy("sq", 2, 8)
+	col.ItemIn(`a"b`)
+
+	var sb strings.Builder
+	if err := col.WritePrometheus(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP pipeline_items_in_total Items received by the stage.
+# TYPE pipeline_items_in_total counter
+pipeline_items_in_total{stage="a\"b"} 1
+pipeline_items_in_total{stage="sq"} 1
+# HELP pipeline_items_out_total Items sent downstream by the stage.
+# TYPE pipeline_items_out_total counter
+pipeline_items_out_total{stage="a\"b"} 0
+pipeline_items_out_total{stage="sq"} 1
+# HELP pipeline_recv_blocked_seconds_total Time spent waiting for upstream.
+# TYPE pipeline_recv_blocked_seconds_total counter
+pipeline_recv_blocked_seconds_total{stage="a\"b"} 0
+pipeline_recv_blocked_seconds_total{stage="sq"} 1.5
+# HELP pipeline_send_blocked_seconds_total Time spent waiting for downstream.
+# TYPE pipeline_send_blocked_seconds_total counter
+pipeline_send_blocked_seconds_total{stage="a\"b"} 0
+pipeline_send_blocked_seconds_total{stage="sq"} 0
+# HELP pipeline_channel_length Items buffered in the input channel.
+# TYPE pipeline_channel_length gauge
+pipeline_channel_length{stage="a\"b"} 0
+pipeline_channel_length{stage="sq"} 2
+# HELP pipeline_channel_capacity Capacity of the input channel.
+# TYPE pipeline_channel_capacity gauge
+pipeline_channel_capacity{stage="a\"b"} 0
+pipeline_channel_capacity{stage="sq"} 8
+`
+	if got := sb.String(); !strings.HasPrefix(got, want) {
+		t.Fatalf("got\n%s\nwant prefix\n%s", got, want)
+	}
+	for _, line := range []string{
+		`pipeline_item_duration_seconds_bucket{stage="sq",le="0.005"} 1`,
+		`pipeline_item_duration_seconds_bucket{stage="sq",le="1"} 1`,
+		`pipeline_item_duration_seconds_bucket{stage="sq",le="2.5"} 2`,
+		`pipeline_item_duration_seconds_bucket{stage="sq",le="+Inf"} 2`,
+		`pipeline_item_duration_seconds_sum{stage="sq"} 2.005`,
+		`pipeline_item_duration_seconds_count{stage="sq"} 2`,
+		`pipeline_item_duration_seconds_count{stage="a\"b"} 0`,
+	} {
+		if !strings.Contains(sb.String(), line+"\n") {
+			t.Errorf("missing line %s", line)
+		}
+	}
+}
+
+func TestCollectorServeHTTP(t *testing.T) {
+	col := NewCollector()
+	col.ItemIn("sq")
+	rec := httptest.NewRecorder()
+	col.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("got Content-Type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `pipeline_items_in_total{stage="sq"} 1`) {
+		t.Fatalf("unexpected body:\n%s", rec.Body)
+	}
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Option 配置阶段的可选行为。
+// 调用用户函数的阶段 (Map、Repeat、MapErr、Pool) 支持全部选项；其余阶段 (Take、FanInWith、Batch 等)
+// 不执行用户代码或自行处理其错误，只使用 WithName、WithInstrument、WithLogger 与 WithWorkerID
+type Option func(*config)
+
+type config struct {
+	panicPolicy PanicPolicy
+	maxRestarts int
+	onPanic     func(*PanicError)
+	cancel      context.CancelCauseFunc
+
+	heartbeat     chan<- Heartbeat
+	pulseInterval time.Duration
+
+	name       string
+	instrument Instrument
+	logger     *slog.Logger
+	worker     int
+
+	onExit func() // 阶段例程最终退出 (不再重启) 后调用
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{panicPolicy: AbortPipeline, maxRestarts: 3}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithPanicPolicy 设置阶段例程发生 panic 时的处理策略，默认为 AbortPipeline
+func WithPanicPolicy(policy PanicPolicy) Option {
+	return func(c *config) { c.panicPolicy = policy }
+}
+
+// WithMaxRestarts 设置 RestartWorker 策略下每个 worker 的最大重启次数 (默认 3)，超出后按 AbortPipeline 处理
+func WithMaxRestarts(n int) Option {
+	return func(c *config) { c.maxRestarts = n }
+}
+
+// WithPanicHandler 设置 panic 被恢复后的回调，可用于记录日志
+func WithPanicHandler(fn func(*PanicError)) Option {
+	return func(c *config) { c.onPanic = fn }
+}
+
+// WithCancel 设置 AbortPipeline 时调用的取消函数，panic 将作为取消原因 (context.Cause)
+func WithCancel(cancel context.CancelCauseFunc) Option {
+	return func(c *config) { c.cancel = cancel }
+}
+
+// withExitHook 返回追加了退出回调的 opts 副本，供组合阶段得知内部阶段何时全部退出
+func withExitHook(opts []Option, fn func()) []Option {
+	return append(opts[:len(opts):len(opts)], func(c *config) { c.onExit = fn })
+}
+
+// exited 在阶段的例程全部退出后调用 onExit
+func (c *config) exited() {
+	if c.onExit != nil {
+		c.onExit()
+	}
+}
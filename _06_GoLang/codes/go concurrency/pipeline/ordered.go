@@ -0,0 +1,201 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSequenceGap 表示 OrderedFanIn 的输入缺少某个序号，其后缓冲的项无法按序输出而被丢弃
+var ErrSequenceGap = errors.New("pipeline: sequence gap")
+
+// Sequenced 为值附加输入序号，用于在并行处理后恢复原始顺序
+type Sequenced[T any] struct {
+	Seq   uint64
+	Value T
+}
+
+// Sequence 按到达顺序为 in 中的值从 0 开始编号
+func Sequence[T any](ctx context.Context, in <-chan T, opts ...Option) <-chan Sequenced[T] {
+	var seq uint64
+	return Map(ctx, in, func(v T) Sequenced[T] {
+		s := Sequenced[T]{Seq: seq, Value: v}
+		seq++
+		return s
+	}, append([]Option{WithName("sequence")}, opts...)...)
+}
+
+// OrderedFanOut 为 in 编号后启动 n 个 worker 并行调用 fn，结果保留输入序号；
+// 每个 worker 按序号递增的顺序输出，可直接交给 OrderedFanIn 重排。
+// 编号与 worker 运行在由 ctx 派生的 ctx 上：AbortPipeline 策略下 fn 的 panic 会取消整个有序流水线，
+// 所有 worker 关闭输出，OrderedFanIn 随之在缺口处结束，而不是停在缺失的序号上；
+// 该 panic 作为取消原因交给 opts 中 WithCancel 设置的取消函数 (如有)。
+// SkipItem 与 RestartWorker 策略会丢弃引发 panic 的项并留下序号缺口，OrderedFanIn 在缺口处结束并报告 ErrSequenceGap
+func OrderedFanOut[T, R any](ctx context.Context, n int, in <-chan T, fn func(T) R, opts ...Option) ([]<-chan Sequenced[R], error) {
+	if err := checkWorkers(ctx, n); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	abort := newConfig(opts).cancel
+	opts = append(opts[:len(opts):len(opts)], WithCancel(func(cause error) {
+		cancel(cause)
+		if abort != nil {
+			abort(cause)
+		}
+	}))
+	// 所有 worker 退出后释放派生的 ctx
+	var wg sync.WaitGroup
+	wg.Add(n)
+	opts = withExitHook(opts, wg.Done)
+	go func() {
+		wg.Wait()
+		cancel(nil)
+	}()
+
+	worker := 0
+	work := func(ctx context.Context, in <-chan Sequenced[T]) <-chan Sequenced[R] {
+		worker++
+		return Map(ctx, in, func(s Sequenced[T]) Sequenced[R] {
+			return Sequenced[R]{Seq: s.Seq, Value: fn(s.Value)}
+		}, withWorker(opts, worker-1)...)
+	}
+	return FanOut(ctx, n, Sequence(ctx, in), work)
+}
+
+// OrderedFanIn 合并多个已编号的通道，并借助重排缓冲按序号从 0 开始依次输出。
+// 每个输入通道内的序号必须递增且序号不能有缺口；
+// 重排缓冲达到 window 项后，只继续读取没有缓冲项的通道 (序号 next 只可能来自这些通道)，
+// 因此缓冲最多 window+len(chans)-1 项，慢 worker 不会导致内存无界增长。
+// 仍未关闭的通道全部在等待恢复读取 (或全部已关闭) 而缓冲中没有序号 next 时，next 已不可能到达
+// (如上游中止或跳过了 panic 的项)，此时丢弃缓冲、关闭输出，并以包装 ErrSequenceGap 的错误
+// 调用 opts 中 WithCancel 设置的取消函数 (如有)，该错误同时作为 MsgError 记录
+func OrderedFanIn[T any](ctx context.Context, window int, chans ...<-chan Sequenced[T]) <-chan T {
+	return OrderedFanInWith(ctx, window, chans)
+}
+
+// OrderedFanInWith 同 OrderedFanIn，chans 以切片传入以便接受 opts
+func OrderedFanInWith[T any](ctx context.Context, window int, chans []<-chan Sequenced[T], opts ...Option) <-chan T {
+	c := newConfig(opts)
+	pr := c.newProbe("ordered_fan_in")
+	l := c.newStageLog("ordered_fan_in", c.worker)
+	if window < 1 {
+		window = 1
+	}
+	type arrival struct {
+		src  int
+		item Sequenced[T]
+		ok   bool
+	}
+	arrivals := make(chan arrival)
+	resume := make([]chan struct{}, len(chans))
+	stop := make(chan struct{}) // 合并例程已退出
+	forward := func(src int, c <-chan Sequenced[T]) {
+		for {
+			waiting := pr.now()
+			var a arrival
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case a.item, a.ok = <-c:
+				a.src = src
+			}
+			if a.ok {
+				probeReceived(pr, waiting, c)
+				l.received()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case arrivals <- a:
+			}
+			if !a.ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-resume[src]:
+			}
+		}
+	}
+	for i, c := range chans {
+		resume[i] = make(chan struct{}, 1)
+		go forward(i, c)
+	}
+
+	out := make(chan T)
+	// merge 按序号输出，返回缺口错误或 ctx 结束的错误
+	merge := func() error {
+		defer close(stop)
+		buf := make(map[uint64]Sequenced[T])
+		owner := make(map[uint64]int)
+		pending := make([]int, len(chans)) // 各通道在缓冲中的项数
+		var parked []int                   // 等待恢复读取的通道
+		var next uint64
+		gap := func() error {
+			return fmt.Errorf("%w: seq %d never arrived, %d later items dropped", ErrSequenceGap, next, len(buf))
+		}
+		for open := len(chans); open > 0; {
+			var a arrival
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case a = <-arrivals:
+			}
+			if !a.ok {
+				open--
+				if len(parked) == open && len(buf) > 0 {
+					return gap() // 缺口无法填补
+				}
+				continue
+			}
+			buf[a.item.Seq] = a.item
+			owner[a.item.Seq] = a.src
+			pending[a.src]++
+			parked = append(parked, a.src)
+
+			for s, ok := buf[next]; ok; s, ok = buf[next] {
+				sending := pr.now()
+				if err := Send(ctx, out, s.Value); err != nil {
+					return err
+				}
+				pr.sent(sending)
+				l.sent()
+				pending[owner[next]]--
+				delete(buf, next)
+				delete(owner, next)
+				next++
+			}
+			waiting := parked[:0]
+			for _, src := range parked {
+				if len(buf) < window || pending[src] == 0 {
+					resume[src] <- struct{}{}
+				} else {
+					waiting = append(waiting, src)
+				}
+			}
+			parked = waiting
+			if len(parked) == open {
+				return gap() // 缺口无法填补
+			}
+		}
+		return nil
+	}
+	go func() {
+		defer close(out)
+		l.start(0)
+		err := merge()
+		l.exit(err)
+		if errors.Is(err, ErrSequenceGap) && c.cancel != nil {
+			c.cancel(err)
+		}
+	}()
+	return out
+}
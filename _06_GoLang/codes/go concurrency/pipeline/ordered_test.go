@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestOrderedFanOutFanIn(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// 序号越小处理越慢，无序合并必然乱序
+		slowFirst := func(n int) int {
+			time.Sleep(time.Duration(20-n) * time.Millisecond)
+			return sq(n)
+		}
+		outs, err := OrderedFanOut(ctx, 4, Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10), slowFirst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(OrderedFanIn(ctx, 4, outs...))
+		want := []int{1, 4, 9, 16, 25, 36, 49, 64, 81, 100}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestOrderedFanInWindow(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		const window = 4
+		release := make(chan struct{})
+		var processed atomic.Int32
+		blockFirst := func(n int) int {
+			if n == 0 {
+				<-release
+			}
+			processed.Add(1)
+			return n
+		}
+		outs, err := OrderedFanOut(ctx, 2, Repeat(ctx, counter(0)), blockFirst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := OrderedFanIn(ctx, window, outs...)
+
+		// 等待未阻塞的 worker 因重排缓冲已满而停下
+		synctest.Wait()
+		// 缓冲 window 项，外加 worker 已处理但被转发例程阻塞的 1 项
+		if n := processed.Load(); n != window+1 {
+			t.Fatalf("processed %d items ahead of seq 0, want %d", n, window+1)
+		}
+		close(release)
+		got := collect(Take(ctx, out, 20))
+		want := make([]int, 20)
+		for i := range want {
+			want[i] = i
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want 0..19 in order", got)
+		}
+	})
+}
+
+func TestOrderedFanInCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := OrderedFanIn(ctx, 2, make(chan Sequenced[int]))
+		cancel()
+		if _, ok := <-out; ok {
+			t.Fatal("OrderedFanIn should close its output once ctx is canceled")
+		}
+	})
+}
+
+// panicOn5After 返回遇到 5 时先等待 release 关闭再 panic 的 fn，使 5 之前的项先全部输出
+func panicOn5After(release <-chan struct{}) func(int) int {
+	return func(n int) int {
+		if n == 5 {
+			<-release
+			panic("boom")
+		}
+		return n
+	}
+}
+
+// receiveThenRelease 依次接收 want 中的项后关闭 release，再收集剩余的输出
+func receiveThenRelease(t *testing.T, out <-chan int, want []int, release chan struct{}) []int {
+	t.Helper()
+	for _, w := range want {
+		if got := <-out; got != w {
+			t.Fatalf("got %d, want %d", got, w)
+		}
+	}
+	close(release)
+	return collect(out)
+}
+
+func TestOrderedFanOutPanicAborts(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(nil)
+		release := make(chan struct{})
+		outs, err := OrderedFanOut(ctx, 3, Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10), panicOn5After(release), WithCancel(cancel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 中止时尚未输出的项被丢弃，输出关闭而不是停在缺失的序号上
+		if rest := receiveThenRelease(t, OrderedFanIn(ctx, 4, outs...), []int{1, 2, 3, 4}, release); len(rest) != 0 {
+			t.Fatalf("got %v after the panic, want none", rest)
+		}
+		var perr *PanicError
+		if !errors.As(context.Cause(ctx), &perr) || perr.Value != "boom" {
+			t.Fatalf("got cause %v, want the recovered panic", context.Cause(ctx))
+		}
+	})
+}
+
+func TestOrderedFanOutPanicWithoutCancel(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		release := make(chan struct{})
+		outs, err := OrderedFanOut(ctx, 3, Repeat(ctx, counter(1)), panicOn5After(release))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rest := receiveThenRelease(t, OrderedFanIn(ctx, 4, outs...), []int{1, 2, 3, 4}, release); len(rest) != 0 {
+			t.Fatalf("got %v after the panic, want none", rest)
+		}
+		if ctx.Err() != nil {
+			t.Fatal("the caller's ctx should not be canceled without WithCancel")
+		}
+	})
+}
+
+func TestOrderedFanOutPanicLeavesGap(t *testing.T) {
+	for _, policy := range []PanicPolicy{SkipItem, RestartWorker} {
+		synctest.Test(t, func(t *testing.T) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			release := make(chan struct{})
+			outs, err := OrderedFanOut(ctx, 3, Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8), panicOn5After(release), WithPanicPolicy(policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 5 被丢弃，其后的项停在缺口之后，所有 worker 关闭时报告缺口
+			out := OrderedFanInWith(ctx, 8, outs, WithCancel(cancel))
+			if rest := receiveThenRelease(t, out, []int{1, 2, 3, 4}, release); len(rest) != 0 {
+				t.Fatalf("%v: got %v after the gap, want none", policy, rest)
+			}
+			if err := context.Cause(ctx); !errors.Is(err, ErrSequenceGap) || !strings.Contains(err.Error(), "seq 4 never arrived, 3 later items") {
+				t.Fatalf("%v: got cause %v, want a gap at seq 4 with 3 items dropped", policy, err)
+			}
+		})
+	}
+}
+
+func TestOrderedFanInGapAfterClose(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(nil)
+		a, b := make(chan Sequenced[int]), make(chan Sequenced[int])
+		out := OrderedFanInWith(ctx, 1, []<-chan Sequenced[int]{a, b}, WithCancel(cancel))
+		a <- Sequenced[int]{Seq: 1, Value: 1} // 重排缓冲已满，a 等待恢复读取
+		synctest.Wait()
+		close(b) // 序号 0 不会再到达
+		if got := collect(out); len(got) != 0 {
+			t.Fatalf("got %v, want none", got)
+		}
+		if !errors.Is(context.Cause(ctx), ErrSequenceGap) {
+			t.Fatalf("got cause %v, want %v", context.Cause(ctx), ErrSequenceGap)
+		}
+		close(a)
+	})
+}
+
+func TestOrderedFanInGapReported(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(nil)
+		var logs bytes.Buffer
+		in := make(chan Sequenced[int], 4)
+		for _, seq := range []uint64{0, 1, 3, 4} { // 序号 2 缺失
+			in <- Sequenced[int]{Seq: seq, Value: int(seq)}
+		}
+		close(in)
+		out := OrderedFanInWith(ctx, 4, []<-chan Sequenced[int]{in},
+			WithCancel(cancel), WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+		if got := collect(out); !slices.Equal(got, []int{0, 1}) {
+			t.Fatalf("got %v, want [0 1]", got)
+		}
+		if err := context.Cause(ctx); !errors.Is(err, ErrSequenceGap) || !strings.Contains(err.Error(), "seq 2") {
+			t.Fatalf("got cause %v, want a gap at seq 2", err)
+		}
+		if !strings.Contains(logs.String(), MsgError) {
+			t.Fatalf("the gap was not logged as %q:\n%s", MsgError, logs.String())
+		}
+	})
+}
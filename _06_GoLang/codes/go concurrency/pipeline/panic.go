@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// PanicPolicy 决定阶段例程从 panic 中恢复后的行为
+type PanicPolicy int
+
+const (
+	AbortPipeline PanicPolicy = iota // 停止该阶段并中止流水线
+	SkipItem                         // 丢弃引发 panic 的项，继续处理下一项
+	RestartWorker                    // 丢弃该项并在新例程中重启 worker
+)
+
+func (p PanicPolicy) String() string {
+	switch p {
+	case AbortPipeline:
+		return "abort"
+	case SkipItem:
+		return "skip"
+	case RestartWorker:
+		return "restart"
+	}
+	return fmt.Sprintf("PanicPolicy(%d)", int(p))
+}
+
+// PanicError 是从阶段例程中恢复的 panic 及其堆栈
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("pipeline: recovered panic: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap 在 panic 值为 error 时返回该错误
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// protect 调用 fn，并将其 panic 转换为 *PanicError
+func protect(fn func()) (perr *PanicError) {
+	defer func() {
+		if p := recover(); p != nil {
+			perr = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// recovered 报告 perr，SkipItem 时返回 nil 使例程继续，否则返回 perr 交由 spawn 处理
+func (c *config) recovered(l *stageLog, perr *PanicError) error {
+	if c.onPanic != nil {
+		c.onPanic(perr)
+	}
+	l.panicked(perr, slog.String("policy", c.panicPolicy.String()))
+	if c.panicPolicy == SkipItem {
+		return nil
+	}
+	return perr
+}
+
+// spawn 在新例程中运行 loop，loop 返回后以其错误调用 exit，并通过 l 记录启动与退出。
+// loop 因 panic 返回且策略为 RestartWorker 时，在新例程中重新运行 loop (最多 maxRestarts 次)；
+// 其余 panic 按 AbortPipeline 处理：调用 WithCancel 设置的取消函数
+func (c *config) spawn(l *stageLog, loop func() error, exit func(error)) {
+	restarts := 0
+	var run func()
+	run = func() {
+		l.start(restarts)
+		err := loop()
+		var perr *PanicError
+		if errors.As(err, &perr) {
+			if c.panicPolicy == RestartWorker && restarts < c.maxRestarts {
+				restarts++
+				go run()
+				return
+			}
+			if c.cancel != nil {
+				c.cancel(perr)
+			}
+		}
+		l.exit(err)
+		exit(err)
+		c.exited()
+	}
+	go run()
+}
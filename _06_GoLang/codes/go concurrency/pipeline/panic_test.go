@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+)
+
+var errBoom = errors.New("boom")
+
+// panicOn3 在处理 3 时 panic
+func panicOn3(n int) int {
+	if n == 3 {
+		panic(errBoom)
+	}
+	return n
+}
+
+func TestMapPanicSkip(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var recovered []*PanicError
+		out := Map(ctx, Generate(ctx, 1, 2, 3, 4, 5), panicOn3,
+			WithPanicPolicy(SkipItem),
+			WithPanicHandler(func(perr *PanicError) { recovered = append(recovered, perr) }))
+		got := collect(out)
+		if !slices.Equal(got, []int{1, 2, 4, 5}) {
+			t.Fatalf("got %v, want [1 2 4 5]", got)
+		}
+		if len(recovered) != 1 {
+			t.Fatalf("got %d recovered panics, want 1", len(recovered))
+		}
+		if !errors.Is(recovered[0], errBoom) {
+			t.Fatalf("PanicError should unwrap to %v", errBoom)
+		}
+		if !strings.Contains(string(recovered[0].Stack), "panicOn3") {
+			t.Fatalf("stack trace should contain the panicking function:\n%s", recovered[0].Stack)
+		}
+	})
+}
+
+func TestMapPanicRestart(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(nil)
+		var panics atomic.Int32
+		panicOdd := func(n int) int {
+			if n%2 == 1 {
+				panic("odd")
+			}
+			return n
+		}
+		out := Map(ctx, Generate(ctx, 0, 1, 2, 3, 4, 5, 6), panicOdd,
+			WithPanicPolicy(RestartWorker),
+			WithMaxRestarts(2),
+			WithPanicHandler(func(*PanicError) { panics.Add(1) }),
+			WithCancel(cancel))
+		got := collect(out)
+		// 第三次 panic 超出重启次数，按 AbortPipeline 处理
+		if !slices.Equal(got, []int{0, 2, 4}) {
+			t.Fatalf("got %v, want [0 2 4]", got)
+		}
+		if panics.Load() != 3 {
+			t.Fatalf("got %d panics, want 3", panics.Load())
+		}
+		var perr *PanicError
+		if !errors.As(context.Cause(ctx), &perr) || perr.Value != "odd" {
+			t.Fatalf("got cause %v, want the recovered panic", context.Cause(ctx))
+		}
+	})
+}
+
+func TestMapPanicAbort(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(nil)
+		got := collect(Map(ctx, Generate(ctx, 1, 2, 3, 4, 5), panicOn3, WithCancel(cancel)))
+		if !slices.Equal(got, []int{1, 2}) {
+			t.Fatalf("got %v, want [1 2]", got)
+		}
+		if !errors.Is(context.Cause(ctx), errBoom) {
+			t.Fatalf("got cause %v, want %v", context.Cause(ctx), errBoom)
+		}
+	})
+}
+
+func TestRepeatPanicSkip(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		next := counter(1)
+		got := collect(Take(ctx, Repeat(ctx, func() int { return panicOn3(next()) }, WithPanicPolicy(SkipItem)), 4))
+		if !slices.Equal(got, []int{1, 2, 4, 5}) {
+			t.Fatalf("got %v, want [1 2 4 5]", got)
+		}
+	})
+}
+
+func TestFanOutWorkPanic(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		work := func(ctx context.Context, in <-chan int) <-chan int {
+			return Map(ctx, in, panicOn3, WithPanicPolicy(SkipItem))
+		}
+		outs, err := FanOut(ctx, 3, Generate(ctx, 1, 2, 3, 4, 5), work)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(FanIn(ctx, outs...))
+		slices.Sort(got)
+		if !slices.Equal(got, []int{1, 2, 4, 5}) {
+			t.Fatalf("got %v, want [1 2 4 5]", got)
+		}
+	})
+}
+
+func TestMapErrPanicAbort(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		stage := func(ctx context.Context, n int) (int, error) { return panicOn3(n), nil }
+		outs, err := FanOutErr(g, 2, Repeat(ctx, counter(1)), stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range FanIn(ctx, outs...) {
+		}
+		var perr *PanicError
+		if err := g.Wait(); !errors.As(err, &perr) || !errors.Is(err, errBoom) {
+			t.Fatalf("got err %v, want a *PanicError wrapping %v", err, errBoom)
+		}
+	})
+}
+
+func TestPoolPanicAbort(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p, err := NewPool(ctx, 2, 0, Repeat(ctx, counter(1)), panicOn3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range p.Out() {
+		}
+		if !errors.Is(p.Err(), errBoom) {
+			t.Fatalf("got err %v, want %v", p.Err(), errBoom)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+/*
+! 包 pipeline 提供可复用的泛型通道流水线阶段
+- 每个阶段接收上游 <-chan T，启动一个例程处理，并返回下游 <-chan R
+- 每个阶段的第一个参数都是 context.Context，ctx 取消或超时后阶段退出并关闭其输出通道
+- 阶段可任意串联：Take(ctx, Map(ctx, Generate(ctx, 1, 2, 3), sq), 2)
+*/
+package pipeline
+
+import "context"
+
+// Send 发送 v 到 out；ctx 先结束时放弃发送并返回 ctx.Err()
+func Send[T any](ctx context.Context, out chan<- T, v T) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case out <- v:
+		return nil
+	}
+}
+
+// Collect 读取 in 直到其关闭，返回已读取的值；
+// 上游阶段因 ctx 结束而提前关闭时无法与正常关闭区分，因此只要 ctx 已结束就返回 ctx.Err()
+func Collect[T any](ctx context.Context, in <-chan T) ([]T, error) {
+	var rt []T
+	for {
+		select {
+		case <-ctx.Done():
+			return rt, ctx.Err()
+		case v, ok := <-in:
+			if !ok {
+				return rt, ctx.Err()
+			}
+			rt = append(rt, v)
+		}
+	}
+}
+
+// Generate 依次发送 values 到返回的通道，发送完毕后关闭通道
+func Generate[T any](ctx context.Context, values ...T) <-chan T {
+	return GenerateWith(ctx, values)
+}
+
+// GenerateWith 同 Generate，values 以切片传入以便接受 opts
+func GenerateWith[T any](ctx context.Context, values []T, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("generate")
+		l := c.newStageLog("generate", c.worker)
+		defer l.lifecycle(ctx)()
+		for _, v := range values {
+			sending := pr.now()
+			if Send(ctx, out, v) != nil {
+				return
+			}
+			pr.sent(sending)
+			l.sent()
+		}
+	}()
+	return out
+}
+
+// Map 对 in 中的每个值调用 fn，并将结果发送到下游；fn 的 panic 按 WithPanicPolicy 处理
+func Map[T, R any](ctx context.Context, in <-chan T, fn func(T) R, opts ...Option) <-chan R {
+	c := newConfig(opts)
+	out := make(chan R)
+	l := c.newStageLog("map", c.worker)
+	c.spawn(l, func() error {
+		p := c.newPulse()
+		defer p.stop()
+		pr := c.newProbe("map")
+		waiting := pr.now()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-p.C():
+				p.beat()
+			case v, ok := <-in:
+				if !ok {
+					return nil
+				}
+				probeReceived(pr, waiting, in)
+				l.received()
+				start := pr.now()
+				var r R
+				if perr := protect(func() { r = fn(v) }); perr != nil {
+					if err := c.recovered(l, perr); err != nil {
+						return err
+					}
+					waiting = pr.now()
+					continue
+				}
+				pr.processed(start)
+				p.work()
+				sending := pr.now()
+				if err := sendPulsing(ctx, out, r, p); err != nil {
+					return err
+				}
+				pr.sent(sending)
+				l.sent()
+				waiting = pr.now()
+			}
+		}
+	}, func(error) { close(out) })
+	return out
+}
+
+// Repeat 重复调用 fn 并持续发送其结果，直到 ctx 结束；fn 的 panic 按 WithPanicPolicy 处理
+func Repeat[T any](ctx context.Context, fn func() T, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	out := make(chan T)
+	l := c.newStageLog("repeat", c.worker)
+	c.spawn(l, func() error {
+		p := c.newPulse()
+		defer p.stop()
+		pr := c.newProbe("repeat")
+		for ctx.Err() == nil {
+			start := pr.now()
+			var v T
+			if perr := protect(func() { v = fn() }); perr != nil {
+				if err := c.recovered(l, perr); err != nil {
+					return err
+				}
+				continue
+			}
+			pr.processed(start)
+			p.work()
+			sending := pr.now()
+			if err := sendPulsing(ctx, out, v, p); err != nil {
+				return err
+			}
+			pr.sent(sending)
+			l.sent()
+		}
+		return ctx.Err()
+	}, func(error) { close(out) })
+	return out
+}
+
+// Take 从 in 中最多取 n 个值；in 提前关闭时输出随之关闭
+func Take[T any](ctx context.Context, in <-chan T, n int, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("take")
+		l := c.newStageLog("take", c.worker)
+		defer l.lifecycle(ctx)()
+		for i := 0; i < n; i++ {
+			waiting := pr.now()
+			var v T
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok = <-in:
+				if !ok {
+					return
+				}
+			}
+			probeReceived(pr, waiting, in)
+			l.received()
+			sending := pr.now()
+			if Send(ctx, out, v) != nil {
+				return
+			}
+			pr.sent(sending)
+			l.sent()
+		}
+	}()
+	return out
+}
+
+// OrDone 转发 in 中的值，直到 in 关闭或 ctx 结束
+func OrDone[T any](ctx context.Context, in <-chan T, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("or_done")
+		l := c.newStageLog("or_done", c.worker)
+		defer l.lifecycle(ctx)()
+		waiting := pr.now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				probeReceived(pr, waiting, in)
+				l.received()
+				sending := pr.now()
+				if Send(ctx, out, v) != nil {
+					return
+				}
+				pr.sent(sending)
+				l.sent()
+				waiting = pr.now()
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func sq(n int) int { return n * n }
+
+func collect[T any](c <-chan T) []T {
+	var rt []T
+	for v := range c {
+		rt = append(rt, v)
+	}
+	return rt
+}
+
+func counter(start int) func() int {
+	return func() int {
+		start++
+		return start - 1
+	}
+}
+
+func TestGenerateMap(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		got := collect(Map(ctx, Generate(ctx, 1, 2, 3, 4, 5, 66), sq))
+		want := []int{1, 4, 9, 16, 25, 4356}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestRepeatTake(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		got := collect(Take(ctx, Repeat(ctx, counter(10)), 5))
+		want := []int{10, 11, 12, 13, 14}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestTakeClosedInput(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		got := collect(Take(ctx, Generate(ctx, 1, 2), 5))
+		if !slices.Equal(got, []int{1, 2}) {
+			t.Fatalf("got %v, want [1 2]", got)
+		}
+	})
+}
+
+func TestOrDone(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan string)
+		out := OrDone(ctx, in)
+		go func() { in <- "moo" }()
+		if v := <-out; v != "moo" {
+			t.Fatalf("got %q, want %q", v, "moo")
+		}
+		cancel()
+		if _, ok := <-out; ok {
+			t.Fatal("OrDone should close its output once ctx is canceled")
+		}
+	})
+}
+
+func TestCollectDeadline(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		// 每 3ms 产出一项，截止前恰好产出 3 项
+		slow := func() int {
+			select {
+			case <-ctx.Done():
+			case <-time.After(3 * time.Millisecond):
+			}
+			return 1
+		}
+		got, err := Collect(ctx, Repeat(ctx, slow))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got err %v, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed != 10*time.Millisecond {
+			t.Fatalf("Collect returned after %v, want 10ms", elapsed)
+		}
+		if !slices.Equal(got, []int{1, 1, 1}) {
+			t.Fatalf("got %v, want [1 1 1]", got)
+		}
+	})
+}
+
+func TestSendCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := Send(ctx, make(chan int), 1); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got err %v, want %v", err, context.Canceled)
+		}
+	})
+}
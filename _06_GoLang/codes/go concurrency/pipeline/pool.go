@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pool 是可在运行中调整大小的 worker 池；worker 数量不受 CPU 数限制，适合 I/O 密集型工作
+type Pool[T, R any] struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	conf   *config
+	fn     func(T) R
+	queue  chan T
+	out    chan R
+	active atomic.Int64
+
+	mu      sync.Mutex
+	quits   []chan struct{} // 每个存活 worker 的退出信号
+	closed  bool            // 输出已开始关闭，不再启动新 worker
+	wg      sync.WaitGroup
+	drained chan struct{}
+	once    sync.Once
+	done    chan struct{}
+	errOnce sync.Once
+	err     error
+}
+
+// NewPool 启动 size 个 worker 处理 in，queueSize 为内部队列容量；
+// in 关闭且队列排空后 (或 ctx 结束后) 关闭 Out。每个 worker 按 opts 报告度量 (阶段名默认为 "pool")、
+// 记录日志并发出心跳，输入通道的占用即内部队列的占用。
+// fn 的 panic 按 WithPanicPolicy 处理，AbortPipeline 时停止整个 Pool 并由 Err 返回该 panic
+func NewPool[T, R any](ctx context.Context, size, queueSize int, in <-chan T, fn func(T) R, opts ...Option) (*Pool[T, R], error) {
+	if err := checkWorkers(ctx, size); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	p := &Pool[T, R]{
+		ctx:     ctx,
+		cancel:  cancel,
+		conf:    newConfig(opts),
+		fn:      fn,
+		queue:   make(chan T, max(queueSize, 0)),
+		out:     make(chan R),
+		drained: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	abort := p.conf.cancel
+	p.conf.cancel = func(cause error) {
+		p.fail(cause)
+		if abort != nil {
+			abort(cause)
+		}
+	}
+	go p.feed(in)
+	p.Resize(size)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-p.drained:
+		}
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		p.wg.Wait()
+		close(p.out)
+		close(p.done)
+		p.cancel(nil)
+	}()
+	return p, nil
+}
+
+// Err 返回导致 Pool 中止的错误 (如 AbortPipeline 策略下恢复的 *PanicError)
+func (p *Pool[T, R]) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *Pool[T, R]) fail(err error) {
+	p.errOnce.Do(func() {
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+		p.cancel(err)
+	})
+}
+
+// Out 返回汇总了所有 worker 结果的输出通道
+func (p *Pool[T, R]) Out() <-chan R { return p.out }
+
+// Resize 将 worker 数量调整为 n；缩容时 worker 处理完当前项后退出。n 为 0 时暂停处理
+func (p *Pool[T, R]) Resize(n int) error {
+	if n < 0 {
+		return fmt.Errorf("pipeline: pool size(%d) is negative", n)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	for len(p.quits) < n {
+		quit := make(chan struct{})
+		p.quits = append(p.quits, quit)
+		p.wg.Add(1)
+		l := p.conf.newStageLog("pool", len(p.quits)-1)
+		p.conf.spawn(l, func() error { return p.work(l, quit) }, func(error) { p.wg.Done() })
+	}
+	for len(p.quits) > n {
+		last := len(p.quits) - 1
+		close(p.quits[last])
+		p.quits = p.quits[:last]
+	}
+	return nil
+}
+
+// Size 返回当前的目标 worker 数量
+func (p *Pool[T, R]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.quits)
+}
+
+// Active 返回正在处理数据项的 worker 数量
+func (p *Pool[T, R]) Active() int { return int(p.active.Load()) }
+
+// QueueDepth 返回内部队列中等待处理的项数
+func (p *Pool[T, R]) QueueDepth() int { return len(p.queue) }
+
+// feed 将 in 转入内部队列
+func (p *Pool[T, R]) feed(in <-chan T) {
+	defer close(p.queue)
+	for v := range OrDone(p.ctx, in) {
+		if Send(p.ctx, p.queue, v) != nil {
+			return
+		}
+	}
+}
+
+func (p *Pool[T, R]) work(l *stageLog, quit <-chan struct{}) error {
+	hb := p.conf.newPulse()
+	defer hb.stop()
+	pr := p.conf.newProbe("pool")
+	waiting := pr.now()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case <-quit:
+			return nil
+		case <-hb.C():
+			hb.beat()
+		case v, ok := <-p.queue:
+			if !ok {
+				p.once.Do(func() { close(p.drained) })
+				return nil
+			}
+			probeReceived(pr, waiting, p.queue)
+			l.received()
+			start := pr.now()
+			var r R
+			p.active.Add(1)
+			perr := protect(func() { r = p.fn(v) })
+			p.active.Add(-1)
+			if perr != nil {
+				if err := p.conf.recovered(l, perr); err != nil {
+					return err
+				}
+				waiting = pr.now()
+				continue
+			}
+			pr.processed(start)
+			hb.work()
+			sending := pr.now()
+			if err := sendPulsing(p.ctx, p.out, r, hb); err != nil {
+				return err
+			}
+			pr.sent(sending)
+			l.sent()
+			waiting = pr.now()
+		}
+	}
+}
+
+// AutoscalePolicy 描述基于积压的自动扩缩容策略
+type AutoscalePolicy struct {
+	Min, Max int           // worker 数量范围
+	Step     int           // 每次调整的 worker 数量，默认为 1
+	High     int           // 队列深度不小于 High 时扩容，必须至少为 1
+	Interval time.Duration // 采样间隔
+}
+
+// Autoscale 按 policy 周期性地调整 Pool 大小，直到 ctx 结束或 Pool 关闭：
+// 积压达到 High 时扩容；队列为空且存在空闲 worker 时缩容
+func (p *Pool[T, R]) Autoscale(policy AutoscalePolicy) error {
+	if policy.Min < 0 || policy.Max < policy.Min || policy.High < 1 || policy.Interval <= 0 {
+		return fmt.Errorf("pipeline: invalid autoscale policy %+v", policy)
+	}
+	step := max(policy.Step, 1)
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+			}
+			size, depth := p.Size(), p.QueueDepth()
+			switch {
+			case depth >= policy.High && size < policy.Max:
+				p.Resize(min(size+step, policy.Max))
+			case depth == 0 && p.Active() < size && size > policy.Min:
+				p.Resize(max(size-step, policy.Min))
+			case size < policy.Min:
+				p.Resize(policy.Min)
+			}
+		}
+	}()
+	return nil
+}
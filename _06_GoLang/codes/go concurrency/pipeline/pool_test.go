@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p, err := NewPool(ctx, 3, 4, Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8), sq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(p.Out())
+		slices.Sort(got)
+		want := []int{1, 4, 9, 16, 25, 36, 49, 64}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestPoolResizeBeyondNumCPU(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		n := runtime.NumCPU() * 4
+		release := make(chan struct{})
+		block := func(v int) int {
+			<-release
+			return v
+		}
+		p, err := NewPool(ctx, 1, n, Repeat(ctx, counter(0)), block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Resize(n); err != nil {
+			t.Fatal(err)
+		}
+		if p.Size() != n {
+			t.Fatalf("got size %d, want %d", p.Size(), n)
+		}
+		synctest.Wait()
+		if p.Active() != n || p.QueueDepth() != n {
+			t.Fatalf("got %d active workers and queue depth %d, want %d and %d", p.Active(), p.QueueDepth(), n, n)
+		}
+
+		if err := p.Resize(2); err != nil {
+			t.Fatal(err)
+		}
+		close(release)
+		for range Take(ctx, p.Out(), 4*n) {
+		}
+		if p.Size() != 2 {
+			t.Fatalf("got size %d, want 2", p.Size())
+		}
+		if err := p.Resize(-1); err == nil {
+			t.Fatal("expected error for negative size")
+		}
+	})
+}
+
+func TestPoolAutoscale(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		release := make(chan struct{})
+		block := func(v int) int {
+			<-release
+			return v
+		}
+		p, err := NewPool(ctx, 1, 16, Repeat(ctx, counter(0)), block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy := AutoscalePolicy{Min: 1, Max: 8, Step: 2, High: 8, Interval: time.Millisecond}
+		if err := p.Autoscale(policy); err != nil {
+			t.Fatal(err)
+		}
+		// 每个采样周期扩容 2 个：1 -> 3 -> 5 -> 7 -> 8
+		for _, want := range []int{3, 5, 7, 8, 8} {
+			time.Sleep(policy.Interval)
+			synctest.Wait()
+			if p.Size() != want {
+				t.Fatalf("got size %d, want %d", p.Size(), want)
+			}
+		}
+		close(release)
+
+		if p.Autoscale(AutoscalePolicy{Min: 2, Max: 1, High: 1, Interval: time.Millisecond}) == nil {
+			t.Fatal("expected error for Max < Min")
+		}
+		// High 为 0 时空队列也满足扩容条件
+		if p.Autoscale(AutoscalePolicy{Min: 1, Max: 8, Interval: time.Millisecond}) == nil {
+			t.Fatal("expected error for High < 1")
+		}
+	})
+}
+
+func TestPoolAutoscaleShrink(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := make(chan int)
+		p, err := NewPool(ctx, 6, 0, in, sq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy := AutoscalePolicy{Min: 2, Max: 8, High: 4, Interval: time.Millisecond}
+		if err := p.Autoscale(policy); err != nil {
+			t.Fatal(err)
+		}
+		// 队列为空且 worker 空闲，每个采样周期缩容 1 个直到 Min
+		for _, want := range []int{5, 4, 3, 2, 2} {
+			time.Sleep(policy.Interval)
+			synctest.Wait()
+			if p.Size() != want {
+				t.Fatalf("got size %d, want %d", p.Size(), want)
+			}
+		}
+		close(in)
+		for range p.Out() {
+		}
+	})
+}
@@ -0,0 +1,102 @@
+/*
+! 包 prime 提供流水线示例使用的 CPU 密集型素数工作负载
+- IsPrime：对 uint64 确定性的 Miller-Rabin 素性测试，每次测试 O(log³ n)
+- TrialDivision：试除到 √n，适合小数或作为对照
+- Sieve / SieveSegment：分段埃拉托斯特尼筛，一次求出 [lo, hi) 内的全部素数
+- 0 与 1 不是素数
+*/
+package prime
+
+import (
+	"context"
+	"math/bits"
+
+	"example/concurrency/pipeline"
+)
+
+// Tester 是可替换的素性测试，如 IsPrime 或 TrialDivision
+type Tester func(n uint64) bool
+
+// 对 n < 3.3e24 确定性的 Miller-Rabin 底数，覆盖整个 uint64 范围
+var witnesses = [...]uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37}
+
+// IsPrime 以确定性的 Miller-Rabin 测试报告 n 是否为素数
+func IsPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for _, p := range witnesses {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+	// n-1 = d·2^s，d 为奇数
+	s := bits.TrailingZeros64(n - 1)
+	d := (n - 1) >> s
+	for _, a := range witnesses {
+		x := powMod(a, d, n)
+		if x == 1 || x == n-1 {
+			continue
+		}
+		composite := true
+		for range s - 1 {
+			x = mulMod(x, x, n)
+			if x == n-1 {
+				composite = false
+				break
+			}
+		}
+		if composite {
+			return false
+		}
+	}
+	return true
+}
+
+// mulMod 返回 a·b mod m，借助 128 位乘积避免溢出
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Rem64(hi, lo, m)
+}
+
+func powMod(a, e, m uint64) uint64 {
+	r := uint64(1)
+	a %= m
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = mulMod(r, a, m)
+		}
+		a = mulMod(a, a, m)
+	}
+	return r
+}
+
+// TrialDivision 以试除到 √n 报告 n 是否为素数
+func TrialDivision(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	if n%2 == 0 {
+		return n == 2
+	}
+	for i := uint64(3); i <= n/i; i += 2 {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter 只转发 in 中 test 判定为素数的值
+func Filter(ctx context.Context, in <-chan uint64, test Tester) <-chan uint64 {
+	out := make(chan uint64)
+	go func() {
+		defer close(out)
+		for n := range pipeline.OrDone(ctx, in) {
+			if test(n) && pipeline.Send(ctx, out, n) != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,330 @@
+package prime
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"testing"
+	"testing/synctest"
+
+	"example/concurrency/pipeline"
+)
+
+// reference 以 math/big 的 ProbablyPrime (对 2^64 以内确定性) 判断素数
+func reference(n uint64) bool {
+	return new(big.Int).SetUint64(n).ProbablyPrime(0)
+}
+
+func TestIsPrimeSmall(t *testing.T) {
+	for n := range uint64(100000) {
+		want := reference(n)
+		if got := IsPrime(n); got != want {
+			t.Fatalf("IsPrime(%d) = %v, want %v", n, got, want)
+		}
+		if got := TrialDivision(n); got != want {
+			t.Fatalf("TrialDivision(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestIsPrimeEdgeCases(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want bool
+	}{
+		{0, false},
+		{1, false},
+		{2, true},
+		{561, false},                 // Carmichael 数
+		{3215031751, false},          // 以 2、3、5、7 为底的强伪素数
+		{3825123056546413051, false}, // 以 2 到 23 为底的强伪素数
+		{4294967291, true},           // 最大的 32 位素数
+		{4294967297, false},          // 2^32+1 = 641 × 6700417
+		{18446744073709551557, true}, // 最大的 64 位素数
+		{math.MaxUint64, false},      // 2^64-1
+		{4611686014132420609, false}, // (2^31-1)^2
+		{1000000000000000003, true},
+	}
+	for _, tt := range tests {
+		if got := IsPrime(tt.n); got != tt.want {
+			t.Errorf("IsPrime(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestIsPrimeLarge(t *testing.T) {
+	for _, lo := range []uint64{400000000, 1 << 40, math.MaxUint64 - 5000} {
+		for n := lo; n < lo+5000 && n >= lo; n++ {
+			if got, want := IsPrime(n), reference(n); got != want {
+				t.Fatalf("IsPrime(%d) = %v, want %v", n, got, want)
+			}
+		}
+	}
+}
+
+func TestSieve(t *testing.T) {
+	for _, r := range [][2]uint64{{0, 1}, {0, 2}, {0, 3}, {0, 100}, {1, 30}, {90, 97}, {90, 98}, {400000000, 400000200}, {1<<40 - 1000, 1 << 40}} {
+		var want []uint64
+		for n := r[0]; n < r[1]; n++ {
+			if reference(n) {
+				want = append(want, n)
+			}
+		}
+		if got := Sieve(r[0], r[1]); !slices.Equal(got, want) {
+			t.Errorf("Sieve(%d, %d) = %v, want %v", r[0], r[1], got, want)
+		}
+	}
+}
+
+func TestBasePrimes(t *testing.T) {
+	if got := BasePrimes(30); !slices.Equal(got, []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := BasePrimes(1); got != nil {
+		t.Fatalf("got %v, want nil", got)
+	}
+	for _, n := range []uint64{0, 1, 3, 4, 99, 100, 101, 1 << 32, math.MaxUint64} {
+		r := isqrt(n)
+		if r*r > n || (r+1) <= n/(r+1) {
+			t.Errorf("isqrt(%d) = %d", n, r)
+		}
+	}
+}
+
+func TestSieveStage(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var got []uint64
+		lo := uint64(0)
+		for s := range SieveStage(ctx, 0, 1000, 64) {
+			if s.Lo != lo || s.Hi != min(lo+64, 1000) {
+				t.Fatalf("got segment [%d, %d), want to start at %d", s.Lo, s.Hi, lo)
+			}
+			lo = s.Hi
+			got = append(got, s.Primes...)
+		}
+		if want := Sieve(0, 1000); lo != 1000 || !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSegments(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		got, _ := pipeline.Collect(ctx, Segments(ctx, 10, 35, 10))
+		want := []Segment{{Lo: 10, Hi: 20}, {Lo: 20, Hi: 30}, {Lo: 30, Hi: 35}}
+		if len(got) != len(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i].Lo != want[i].Lo || got[i].Hi != want[i].Hi {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		}
+		if got, _ := pipeline.Collect(ctx, Segments(ctx, 0, 100, 0)); len(got) != 0 {
+			t.Fatalf("size 0: got %v, want none", got)
+		}
+		if got, _ := pipeline.Collect(ctx, Segments(ctx, math.MaxUint64-5, math.MaxUint64, 4)); len(got) != 2 || got[1].Hi != math.MaxUint64 {
+			t.Fatalf("near MaxUint64: got %v", got)
+		}
+	})
+}
+
+func TestParallelSieve(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		const lo, hi = 1000000, 1010000
+		out, err := ParallelSieve(ctx, lo, hi, 333, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint64
+		next := uint64(lo)
+		for s := range out {
+			if s.Lo != next {
+				t.Fatalf("got segment [%d, %d), want to start at %d", s.Lo, s.Hi, next)
+			}
+			next = s.Hi
+			got = append(got, s.Primes...)
+		}
+		if want := Sieve(lo, hi); next != hi || !slices.Equal(got, want) {
+			t.Fatalf("got %d primes, want %d", len(got), len(want))
+		}
+	})
+	synctest.Test(t, func(t *testing.T) {
+		// ctx 不会结束：返回错误时不应留下任何例程，否则 synctest.Test 报告泄漏
+		if _, err := ParallelSieve(context.Background(), 0, 100, 10, 0); err == nil {
+			t.Fatal("n = 0: want error")
+		}
+	})
+}
+
+func TestParallelSieveCancel(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out, err := ParallelSieve(ctx, 0, 1<<20, 1024, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-out
+		<-out
+		cancel()
+		for range out {
+		}
+		synctest.Wait() // 所有例程已退出，否则 synctest.Test 报告泄漏
+	})
+}
+
+func TestFilter(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := pipeline.Generate[uint64](ctx, 0, 1, 2, 3, 4, 5, 9, 11)
+		got, _ := pipeline.Collect(ctx, Filter(ctx, in, IsPrime))
+		if !slices.Equal(got, []uint64{2, 3, 5, 11}) {
+			t.Fatalf("got %v, want [2 3 5 11]", got)
+		}
+	})
+}
+
+// naive 是原 primeFinder 中的试除法：从 n-1 向下逐个试除，且把 0 与 1 误判为素数
+func naive(n uint64) bool {
+	for i := n - 1; i > 1; i-- {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ? go test -run=^$ -bench=. ./pipeline/prime
+func BenchmarkPrimes(b *testing.B) {
+	const n = 200
+	testers := []struct {
+		name string
+		test Tester
+	}{{"Naive", naive}, {"TrialDivision", TrialDivision}, {"MillerRabin", IsPrime}}
+	for _, lo := range []uint64{10000000, 400000000} {
+		for _, tt := range testers {
+			if tt.name == "Naive" && lo > 10000000 {
+				continue // 每次迭代需要数分钟
+			}
+			b.Run(fmt.Sprintf("%s/%d", tt.name, lo), func(b *testing.B) {
+				for b.Loop() {
+					for i := lo; i < lo+n; i++ {
+						tt.test(i)
+					}
+				}
+			})
+		}
+		b.Run(fmt.Sprintf("Sieve/%d", lo), func(b *testing.B) {
+			for b.Loop() {
+				Sieve(lo, lo+n)
+			}
+		})
+	}
+}
+
+// ? go test -run=^$ -bench=Parallel ./pipeline/prime
+// PerInteger 是逐个整数经通道分发给 worker 的模型；ParallelSieve 每条消息携带一个分段
+func BenchmarkParallel(b *testing.B) {
+	const lo, n = 400000000, 1 << 16
+	const workers = 4
+	b.Run("PerInteger", func(b *testing.B) {
+		for b.Loop() {
+			ctx, cancel := context.WithCancel(context.Background())
+			src := make(chan uint64)
+			go func() {
+				defer close(src)
+				for i := uint64(lo); i < lo+n; i++ {
+					if pipeline.Send(ctx, src, i) != nil {
+						return
+					}
+				}
+			}()
+			outs, _ := pipeline.FanOut(ctx, workers, src, func(ctx context.Context, in <-chan uint64) <-chan uint64 {
+				return Filter(ctx, in, IsPrime)
+			})
+			for range pipeline.FanIn(ctx, outs...) {
+			}
+			cancel()
+		}
+	})
+	b.Run("Sieve", func(b *testing.B) {
+		for b.Loop() {
+			Sieve(lo, lo+n)
+		}
+	})
+	for _, size := range []uint64{256, 4096, 16384} {
+		b.Run(fmt.Sprintf("ParallelSieve/%d", size), func(b *testing.B) {
+			for b.Loop() {
+				ctx, cancel := context.WithCancel(context.Background())
+				out, _ := ParallelSieve(ctx, lo, lo+n, size, workers)
+				for range out {
+				}
+				cancel()
+			}
+		})
+	}
+}
+
+// ? go test -run=^$ -bench=FanOut ./pipeline/prime
+// 比较共享通道的 FanOut 与 FanOutStealing：Integers 逐个整数判断素数，
+// Skewed 中分段长度相差 64 倍，轮流分发会使部分 worker 积压
+func BenchmarkFanOut(b *testing.B) {
+	const lo, n = 400000000, 1 << 14
+	const workers = 4
+	type work = func(context.Context, <-chan uint64) <-chan uint64
+	fanouts := []struct {
+		name   string
+		fanout func(ctx context.Context, n int, in <-chan uint64, work work) ([]<-chan uint64, error)
+	}{
+		{"Shared", pipeline.FanOut[uint64, uint64]},
+		{"Stealing", func(ctx context.Context, n int, in <-chan uint64, work work) ([]<-chan uint64, error) {
+			return pipeline.FanOutStealing(ctx, n, in, work)
+		}},
+	}
+
+	filter := func(ctx context.Context, in <-chan uint64) <-chan uint64 { return Filter(ctx, in, IsPrime) }
+	for _, f := range fanouts {
+		b.Run("Integers/"+f.name, func(b *testing.B) {
+			for b.Loop() {
+				ctx, cancel := context.WithCancel(context.Background())
+				outs, _ := f.fanout(ctx, workers, pipeline.Map(ctx, Segments(ctx, lo, lo+n, 1), func(s Segment) uint64 { return s.Lo }), filter)
+				for range pipeline.FanIn(ctx, outs...) {
+				}
+				cancel()
+			}
+		})
+	}
+
+	// 每 workers 个分段中有一个长分段，各分段的值为其起点，worker 输出分段中的素数个数
+	base := BaseFor(lo + 64*n)
+	count := func(ctx context.Context, in <-chan uint64) <-chan uint64 {
+		return pipeline.Map(ctx, in, func(start uint64) uint64 {
+			size := uint64(1024)
+			if start/1024%workers == 0 {
+				size *= 64
+			}
+			return uint64(len(SieveSegment(start, start+size, base)))
+		})
+	}
+	for _, f := range fanouts {
+		b.Run("Skewed/"+f.name, func(b *testing.B) {
+			for b.Loop() {
+				ctx, cancel := context.WithCancel(context.Background())
+				src := pipeline.Map(ctx, Segments(ctx, lo, lo+n, 1024), func(s Segment) uint64 { return s.Lo })
+				outs, _ := f.fanout(ctx, workers, src, count)
+				for range pipeline.FanIn(ctx, outs...) {
+				}
+				cancel()
+			}
+		})
+	}
+}
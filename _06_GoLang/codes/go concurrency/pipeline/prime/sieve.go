@@ -0,0 +1,139 @@
+package prime
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"example/concurrency/pipeline"
+)
+
+// Segment 是半开区间 [Lo, Hi) 及其中的素数
+type Segment struct {
+	Lo, Hi uint64
+	Primes []uint64
+}
+
+// isqrt 返回 ⌊√n⌋
+func isqrt(n uint64) uint64 {
+	r := uint64(math.Sqrt(float64(n)))
+	for r > 0 && r > n/r { // 浮点误差可能偏大
+		r--
+	}
+	for r+1 <= n/(r+1) {
+		r++
+	}
+	return r
+}
+
+// BasePrimes 以普通埃氏筛返回不超过 limit 的全部素数，用作分段筛的基础素数
+func BasePrimes(limit uint64) []uint64 {
+	if limit < 2 {
+		return nil
+	}
+	composite := make([]bool, limit+1)
+	var primes []uint64
+	for i := uint64(2); i <= limit; i++ {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, i)
+		for j := i * i; j <= limit; j += i {
+			composite[j] = true
+		}
+	}
+	return primes
+}
+
+// BaseFor 返回筛 [.., hi) 所需的基础素数，即不超过 √(hi-1) 的素数
+func BaseFor(hi uint64) []uint64 {
+	if hi < 2 {
+		return nil
+	}
+	return BasePrimes(isqrt(hi - 1))
+}
+
+// SieveSegment 返回 [lo, hi) 内的素数；base 必须 (按升序) 包含不超过 √(hi-1) 的全部素数，
+// 多个分段可共享同一份 base。内存占用为 O(hi-lo)
+func SieveSegment(lo, hi uint64, base []uint64) []uint64 {
+	lo = max(lo, 2)
+	if hi <= lo {
+		return nil
+	}
+	composite := make([]bool, hi-lo)
+	for _, p := range base {
+		if p > (hi-1)/p {
+			break
+		}
+		// 从 max(p², 不小于 lo 的第一个 p 的倍数) 开始划去
+		start := max(p*p, (lo+p-1)/p*p)
+		for j := start; j < hi && j >= start; j += p {
+			composite[j-lo] = true
+		}
+	}
+	var primes []uint64
+	for i, c := range composite {
+		if !c {
+			primes = append(primes, lo+uint64(i))
+		}
+	}
+	return primes
+}
+
+// Sieve 返回 [lo, hi) 内的素数
+func Sieve(lo, hi uint64) []uint64 {
+	return SieveSegment(lo, hi, BaseFor(hi))
+}
+
+// Segments 依次发出 [lo, hi) 中长度为 size 的分段 (不含素数)，最后一段可能较短；size 为 0 时不发出任何分段
+func Segments(ctx context.Context, lo, hi, size uint64) <-chan Segment {
+	out := make(chan Segment)
+	go func() {
+		defer close(out)
+		if size == 0 {
+			return
+		}
+		for seg := lo; seg < hi; {
+			end := min(hi, seg+size)
+			if end < seg { // 溢出
+				end = hi
+			}
+			if pipeline.Send(ctx, out, Segment{Lo: seg, Hi: end}) != nil {
+				return
+			}
+			seg = end
+		}
+	}()
+	return out
+}
+
+// sieveWith 返回用 base 筛分段的阶段函数
+func sieveWith(base []uint64) func(Segment) Segment {
+	return func(s Segment) Segment {
+		s.Primes = SieveSegment(s.Lo, s.Hi, base)
+		return s
+	}
+}
+
+// SieveStage 依次筛 [lo, hi) 中长度为 size 的分段，每个分段作为一个 Segment 发出；
+// 所有分段共享一份基础素数，ctx 结束时停止
+func SieveStage(ctx context.Context, lo, hi, size uint64) <-chan Segment {
+	return pipeline.Map(ctx, Segments(ctx, lo, hi, size), sieveWith(BaseFor(hi)))
+}
+
+// ParallelSieve 以 n 个 worker 并行筛 [lo, hi)：Segments 发出分段，OrderedFanOut 的 worker
+// 共享同一份只读的基础素数筛各自的分段，OrderedFanIn 按分段顺序重组输出。
+// ! 每条通道消息是一个长度为 size 的分段而不是一个整数，通道开销被分摊到 size 个数上；
+// size 过小时通信开销占主导，过大时 worker 之间负载不均、重排缓冲占用更多内存
+func ParallelSieve(ctx context.Context, lo, hi, size uint64, n int) (<-chan Segment, error) {
+	// 先检查 n，否则返回错误时 Segments 的例程无人读取
+	if n < 1 {
+		return nil, fmt.Errorf("prime: n(%d) is less than 1", n)
+	}
+	base := BaseFor(hi)
+	outs, err := pipeline.OrderedFanOut(ctx, n, Segments(ctx, lo, hi, size), sieveWith(base))
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.OrderedFanIn(ctx, 2*n, outs...), nil
+}
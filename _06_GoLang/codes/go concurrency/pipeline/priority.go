@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Prioritized 是带优先级的值，Priority 越大越先发出
+type Prioritized[T any] struct {
+	Priority int
+	Value    T
+}
+
+// agedKey 返回按老化排序的键，键越大越先发出：
+// 等待了 w 的项的有效优先级为 priority + w/aging，所有项以相同速率老化，
+// 因此比较有效优先级等价于比较 priority*aging - 到达时刻，键在入队后不再变化。
+// aging <= 0 时不老化，键即 priority。
+// ? priority*aging 以纳秒计，|priority| 不超过 9e18/aging 时不会溢出 (aging 为 1s 时约 9e9)
+func agedKey(priority int, arrived time.Duration, aging time.Duration) int64 {
+	if aging <= 0 {
+		return int64(priority)
+	}
+	return int64(priority)*int64(aging) - int64(arrived)
+}
+
+type pqItem[T any] struct {
+	key   int64
+	seq   uint64 // 键相同时先到先出
+	value T
+}
+
+// pqHeap 是按 key 降序、seq 升序排列的最大堆
+type pqHeap[T any] []pqItem[T]
+
+func (h pqHeap[T]) Len() int { return len(h) }
+func (h pqHeap[T]) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key > h[j].key
+	}
+	return h[i].seq < h[j].seq
+}
+func (h pqHeap[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *pqHeap[T]) Push(x any)   { *h = append(*h, x.(pqItem[T])) }
+func (h *pqHeap[T]) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	old[len(old)-1] = pqItem[T]{}
+	*h = old[:len(old)-1]
+	return it
+}
+
+// PriorityQueue 缓冲 in 中最多 capacity 个值，下游就绪时总是发出有效优先级最高的值，
+// 优先级相同时先到先出。aging > 0 时值每等待 aging 有效优先级加 1，
+// 持续到达的高优先级值因此不会让低优先级值无限等待；aging <= 0 时按优先级严格排序。
+// 缓冲已满时停止读取 in；in 关闭后发出剩余的值，ctx 结束时丢弃缓冲并关闭输出。
+// ! 只有下游慢于上游、缓冲中积压了值时优先级才有意义，capacity 决定了可重排的范围
+func PriorityQueue[T any](ctx context.Context, in <-chan Prioritized[T], capacity int, aging time.Duration, opts ...Option) <-chan T {
+	c := newConfig(opts)
+	capacity = max(capacity, 1)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("priority_queue")
+		l := c.newStageLog("priority_queue", c.worker)
+		defer l.lifecycle(ctx)()
+		// 收发在同一个 select 中等待，等待时长计入实际就绪的一方
+		waiting := pr.now()
+		epoch := time.Now()
+		var h pqHeap[T]
+		var seq uint64
+		for in != nil || h.Len() > 0 {
+			recv, send := in, chan<- T(nil)
+			if h.Len() >= capacity {
+				recv = nil
+			}
+			var top T
+			if h.Len() > 0 {
+				send, top = out, h[0].value
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-recv:
+				if !ok {
+					in = nil
+					continue
+				}
+				probeReceived(pr, waiting, in)
+				l.received()
+				waiting = pr.now()
+				heap.Push(&h, pqItem[T]{key: agedKey(v.Priority, time.Since(epoch), aging), seq: seq, value: v.Value})
+				seq++
+			case send <- top:
+				heap.Pop(&h)
+				pr.sent(waiting)
+				l.sent()
+				waiting = pr.now()
+			}
+		}
+	}()
+	return out
+}
+
+// PrioritySource 是 PriorityFanIn 的一个输入，Priority 越大越优先
+type PrioritySource[T any] struct {
+	C        <-chan T
+	Priority int
+}
+
+type heldItem[T any] struct {
+	value   T
+	arrived time.Duration
+}
+
+// PriorityFanIn 合并多个通道，下游就绪时优先发出 Priority 较高的来源中已到达的值，
+// 优先级相同时按 sources 中的顺序。aging 的含义同 PriorityQueue：值每等待 aging，
+// 其来源的有效优先级加 1，因此高优先级来源持续有值时低优先级来源也不会饿死。
+// 每个来源最多预先读取三个值 (一个待选、一个在交接缓冲中、一个由转发例程持有等待交接)，
+// 所有来源关闭或 ctx 结束后关闭输出
+func PriorityFanIn[T any](ctx context.Context, aging time.Duration, sources ...PrioritySource[T]) <-chan T {
+	return PriorityFanInWith(ctx, aging, sources)
+}
+
+// PriorityFanInWith 同 PriorityFanIn，sources 以切片传入以便接受 opts
+func PriorityFanInWith[T any](ctx context.Context, aging time.Duration, sources []PrioritySource[T], opts ...Option) <-chan T {
+	c := newConfig(opts)
+	pr := c.newProbe("priority_fan_in")
+	l := c.newStageLog("priority_fan_in", c.worker)
+	out := make(chan T)
+	epoch := time.Now()
+	held := make([]chan heldItem[T], len(sources))
+	notify := make(chan struct{}, 1)
+	wake := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(sources))
+	for i, src := range sources {
+		held[i] = make(chan heldItem[T], 1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				close(held[i])
+				wake()
+			}()
+			for {
+				waiting := pr.now()
+				var v T
+				select {
+				case <-ctx.Done():
+					return
+				case x, ok := <-src.C:
+					if !ok {
+						return
+					}
+					v = x
+				}
+				probeReceived(pr, waiting, src.C)
+				l.received()
+				if Send(ctx, held[i], heldItem[T]{value: v, arrived: time.Since(epoch)}) != nil {
+					return
+				}
+				wake()
+			}
+		}()
+	}
+	go func() {
+		defer close(out)
+		defer l.lifecycle(ctx)()
+		defer wg.Wait() // 转发例程在 ctx 结束或来源关闭后退出
+		pending := make([]heldItem[T], len(sources))
+		has := make([]bool, len(sources))
+		open := len(sources)
+		for {
+			// 收下各来源已到达的值，再选出键最大的一个
+			best := -1
+			var bestKey int64
+			for i := range sources {
+				if !has[i] && held[i] != nil {
+					select {
+					case it, ok := <-held[i]:
+						if ok {
+							pending[i], has[i] = it, true
+						} else {
+							held[i] = nil
+							open--
+						}
+					default:
+					}
+				}
+				if has[i] {
+					if key := agedKey(sources[i].Priority, pending[i].arrived, aging); best < 0 || key > bestKey {
+						best, bestKey = i, key
+					}
+				}
+			}
+			var send chan<- T
+			var top T
+			sending := pr.now()
+			if best >= 0 {
+				send, top = out, pending[best].value
+			} else if open == 0 {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+			case send <- top:
+				pr.sent(sending)
+				l.sent()
+				has[best] = false
+				var zero heldItem[T]
+				pending[best] = zero
+			}
+		}
+	}()
+	return out
+}
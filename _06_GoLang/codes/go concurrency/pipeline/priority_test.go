@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+// prioritized 返回已关闭的、装有 items 的缓冲通道
+func prioritized(items ...Prioritized[string]) <-chan Prioritized[string] {
+	in := make(chan Prioritized[string], len(items))
+	for _, it := range items {
+		in <- it
+	}
+	close(in)
+	return in
+}
+
+func TestPriorityQueue(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := prioritized(
+			Prioritized[string]{1, "a"}, Prioritized[string]{5, "b"}, Prioritized[string]{1, "c"},
+			Prioritized[string]{9, "d"}, Prioritized[string]{5, "e"},
+		)
+		out := PriorityQueue(ctx, in, 10, 0)
+		synctest.Wait() // 下游开始接收前所有值已进入缓冲
+		got := collect(out)
+		if want := []string{"d", "b", "e", "a", "c"}; !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestPriorityQueueAging(t *testing.T) {
+	for _, tt := range []struct {
+		aging time.Duration
+		want  []string
+	}{
+		{0, []string{"high", "mid", "low"}},
+		// low 等待 5s 后有效优先级为 5，高于 mid 的 3
+		{time.Second, []string{"high", "low", "mid"}},
+	} {
+		synctest.Test(t, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			in := make(chan Prioritized[string])
+			out := PriorityQueue(ctx, in, 10, tt.aging)
+			in <- Prioritized[string]{0, "low"}
+			time.Sleep(5 * time.Second)
+			in <- Prioritized[string]{3, "mid"}
+			in <- Prioritized[string]{10, "high"}
+			close(in)
+			synctest.Wait()
+			if got := collect(out); !slices.Equal(got, tt.want) {
+				t.Fatalf("aging %v: got %v, want %v", tt.aging, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriorityQueueCapacity(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := prioritized(
+			Prioritized[string]{1, "a"}, Prioritized[string]{2, "b"}, Prioritized[string]{3, "c"},
+			Prioritized[string]{4, "d"}, Prioritized[string]{5, "e"},
+		)
+		out := PriorityQueue(ctx, in, 2, 0)
+		synctest.Wait()
+		if got := len(in); got != 3 {
+			t.Fatalf("got %d values left in input, want 3", got)
+		}
+		if got := <-out; got != "b" {
+			t.Fatalf("got %q, want the highest of the buffered values", got)
+		}
+		cancel()
+		for range out {
+		}
+	})
+}
+
+func TestPriorityFanIn(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		low, high := Generate(ctx, 1, 2, 3), Generate(ctx, 10, 20, 30)
+		out := PriorityFanIn(ctx, 0,
+			PrioritySource[int]{C: low, Priority: 0},
+			PrioritySource[int]{C: high, Priority: 1},
+		)
+		var got []int
+		for {
+			synctest.Wait() // 每次接收前各来源均已到达
+			v, ok := <-out
+			if !ok {
+				break
+			}
+			got = append(got, v)
+		}
+		if want := []int{10, 20, 30, 1, 2, 3}; !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestPriorityFanInAging(t *testing.T) {
+	for _, tt := range []struct {
+		aging time.Duration
+		want  []int
+	}{{0, []int{2, 1}}, {time.Second, []int{1, 2}}} {
+		synctest.Test(t, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			low, high := make(chan int, 1), make(chan int, 1)
+			out := PriorityFanIn(ctx, tt.aging,
+				PrioritySource[int]{C: low, Priority: 0},
+				PrioritySource[int]{C: high, Priority: 3},
+			)
+			low <- 1
+			close(low)
+			synctest.Wait()
+			time.Sleep(5 * time.Second)
+			high <- 2
+			close(high)
+			synctest.Wait()
+			if got := collect(out); !slices.Equal(got, tt.want) {
+				t.Fatalf("aging %v: got %v, want %v", tt.aging, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriorityFanInCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		a, b := make(chan int), make(chan int)
+		out := PriorityFanIn(ctx, time.Second, PrioritySource[int]{C: a}, PrioritySource[int]{C: b, Priority: 1})
+		cancel()
+		for range out {
+		}
+		synctest.Wait()
+	})
+}
+
+func TestPriorityFanInReadAhead(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int, 10)
+		for i := range 10 {
+			in <- i
+		}
+		out := PriorityFanIn(ctx, 0, PrioritySource[int]{C: in})
+		synctest.Wait() // 下游未接收时最多预先读取三个值
+		if got := 10 - len(in); got != 3 {
+			t.Fatalf("read %d values ahead, want 3", got)
+		}
+		cancel()
+		for range out {
+		}
+	})
+}
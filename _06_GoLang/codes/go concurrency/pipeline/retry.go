@@ -0,0 +1,148 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Jitter 决定如何随机化退避时间，避免大量重试同时发生
+type Jitter int
+
+const (
+	NoJitter    Jitter = iota // 使用原始的指数退避时间 d
+	FullJitter                // 在 [0, d) 中随机
+	EqualJitter               // 在 [d/2, d) 中随机
+)
+
+// RetryPolicy 描述带指数退避的重试策略
+type RetryPolicy struct {
+	MaxAttempts int              // 最大尝试次数 (含第一次)，小于 1 时按 1 处理
+	BaseDelay   time.Duration    // 第一次重试前的退避时间
+	MaxDelay    time.Duration    // 退避时间上限，0 表示不限
+	Multiplier  float64          // 每次重试的退避倍数，小于 1 时按 2 处理
+	Jitter      Jitter           // 退避时间的随机化方式
+	Deadline    time.Duration    // 每项从第一次尝试起的总时限 (含退避)，0 表示不限
+	Retryable   func(error) bool // 判断错误是否值得重试，nil 表示所有错误都重试
+}
+
+// DeadLetter 是重试耗尽或遇到不可重试错误的项
+type DeadLetter[T any] struct {
+	Value    T
+	Err      error // 最后一次尝试的错误
+	Attempts int
+}
+
+// Backoff 返回第 attempt 次失败 (从 1 开始) 后、下一次尝试前的退避时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 2
+	}
+	// 未设置 MaxDelay 时以 time.Duration 的最大值为上限，避免转换时溢出为负数
+	limit := float64(math.MaxInt64)
+	if p.MaxDelay > 0 {
+		limit = float64(p.MaxDelay)
+	}
+	d := float64(p.BaseDelay)
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= mult
+	}
+	d = min(d, limit)
+	switch p.Jitter {
+	case FullJitter:
+		d = rand.Float64() * d
+	case EqualJitter:
+		d = d/2 + rand.Float64()*d/2
+	}
+	if d >= math.MaxInt64 { // float64(math.MaxInt64) 即 2^63，已超出 time.Duration 的范围
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// do 反复调用 attempt 直到成功、错误不可重试、次数耗尽或超出 Deadline，返回尝试次数与最后的错误；
+// attempt 的 panic 视为本次尝试的 *PanicError
+func (p RetryPolicy) do(ctx context.Context, attempt func(ctx context.Context) error) (int, error) {
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
+	}
+	timer := time.NewTimer(0)
+	timer.Stop()
+	defer timer.Stop()
+	for n := 1; ; n++ {
+		var err error
+		if perr := protect(func() { err = attempt(ctx) }); perr != nil {
+			err = perr
+		}
+		if err == nil {
+			return n, nil
+		}
+		if n >= p.MaxAttempts || (p.Retryable != nil && !p.Retryable(err)) {
+			return n, err
+		}
+		timer.Reset(p.Backoff(n))
+		select {
+		case <-ctx.Done():
+			return n, errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Retry 对 in 中的每个值调用 fn，失败时按 policy 退避重试；成功的结果发送到 out，
+// 重试耗尽、错误不可重试或超出每项时限的值发送到 dead。调用者需要同时读取两个通道；
+// ctx 结束时停止处理 (不会产生死信) 并关闭两个通道。度量中发出的项数包含死信
+func Retry[T, R any](ctx context.Context, in <-chan T, fn Stage[T, R], policy RetryPolicy, opts ...Option) (out <-chan R, dead <-chan DeadLetter[T]) {
+	c := newConfig(opts)
+	results := make(chan R)
+	letters := make(chan DeadLetter[T])
+	go func() {
+		defer close(results)
+		defer close(letters)
+		pr := c.newProbe("retry")
+		l := c.newStageLog("retry", c.worker)
+		defer l.lifecycle(ctx)()
+		waiting := pr.now()
+		for {
+			var v T
+			select {
+			case <-ctx.Done():
+				return
+			case x, ok := <-in:
+				if !ok {
+					return
+				}
+				v = x
+			}
+			probeReceived(pr, waiting, in)
+			l.received()
+			start := pr.now()
+			var r R
+			n, err := policy.do(ctx, func(ctx context.Context) (err error) {
+				r, err = fn(ctx, v)
+				return err
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			pr.processed(start)
+			sending := pr.now()
+			if err != nil {
+				if Send(ctx, letters, DeadLetter[T]{Value: v, Err: err, Attempts: n}) != nil {
+					return
+				}
+			} else if Send(ctx, results, r) != nil {
+				return
+			}
+			pr.sent(sending)
+			l.sent()
+			waiting = pr.now()
+		}
+	}()
+	return results, letters
+}
@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+// flaky 对每个值前 failures 次调用返回 errTransient，并记录每次调用的时间
+type flaky struct {
+	start    time.Time
+	failures int
+	calls    map[int][]time.Duration
+}
+
+func (f *flaky) do(ctx context.Context, v int) (int, error) {
+	f.calls[v] = append(f.calls[v], time.Since(f.start))
+	if len(f.calls[v]) <= f.failures {
+		return 0, errTransient
+	}
+	return v, nil
+}
+
+// retryAll 读取 Retry 的两个输出通道
+func retryAll[T, R any](out <-chan R, dead <-chan DeadLetter[T]) ([]R, []DeadLetter[T]) {
+	var rs []R
+	var ds []DeadLetter[T]
+	for out != nil || dead != nil {
+		select {
+		case r, ok := <-out:
+			if !ok {
+				out = nil
+				continue
+			}
+			rs = append(rs, r)
+		case d, ok := <-dead:
+			if !ok {
+				dead = nil
+				continue
+			}
+			ds = append(ds, d)
+		}
+	}
+	return rs, ds
+}
+
+func TestRetryBackoff(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		f := &flaky{start: time.Now(), failures: 2, calls: map[int][]time.Duration{}}
+		policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * ms}
+		got, dead := retryAll(Retry(ctx, Generate(ctx, 7), f.do, policy))
+		if !slices.Equal(got, []int{7}) || len(dead) != 0 {
+			t.Fatalf("got %v and dead letters %v, want [7] and none", got, dead)
+		}
+		// 退避 10ms、20ms
+		if want := []time.Duration{0, 10 * ms, 30 * ms}; !slices.Equal(f.calls[7], want) {
+			t.Fatalf("attempts at %v, want %v", f.calls[7], want)
+		}
+	})
+}
+
+func TestRetryDeadLetter(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		f := &flaky{start: time.Now(), failures: 5, calls: map[int][]time.Duration{}}
+		policy := RetryPolicy{MaxAttempts: 3, BaseDelay: ms}
+		got, dead := retryAll(Retry(ctx, Generate(ctx, 1, 2), f.do, policy))
+		if len(got) != 0 || len(dead) != 2 {
+			t.Fatalf("got %v and %d dead letters, want none and 2", got, len(dead))
+		}
+		for i, d := range dead {
+			if d.Value != i+1 || d.Attempts != 3 || !errors.Is(d.Err, errTransient) {
+				t.Fatalf("unexpected dead letter %+v", d)
+			}
+		}
+	})
+}
+
+func TestRetryClassifier(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errFatal := errors.New("fatal")
+		fn := func(ctx context.Context, v int) (int, error) {
+			if v < 0 {
+				return 0, errFatal
+			}
+			return v, nil
+		}
+		policy := RetryPolicy{
+			MaxAttempts: 5,
+			BaseDelay:   ms,
+			Retryable:   func(err error) bool { return !errors.Is(err, errFatal) },
+		}
+		got, dead := retryAll(Retry(ctx, Generate(ctx, 1, -1, 2), fn, policy))
+		if !slices.Equal(got, []int{1, 2}) {
+			t.Fatalf("got %v, want [1 2]", got)
+		}
+		if len(dead) != 1 || dead[0].Value != -1 || dead[0].Attempts != 1 {
+			t.Fatalf("got dead letters %+v, want -1 after a single attempt", dead)
+		}
+	})
+}
+
+func TestRetryDeadline(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		f := &flaky{start: time.Now(), failures: 10, calls: map[int][]time.Duration{}}
+		// 尝试于 0ms、10ms，下一次在 30ms，超出 25ms 的时限
+		policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * ms, Deadline: 25 * ms}
+		_, dead := retryAll(Retry(ctx, Generate(ctx, 1), f.do, policy))
+		if len(dead) != 1 || dead[0].Attempts != 2 {
+			t.Fatalf("got dead letters %+v, want one after 2 attempts", dead)
+		}
+		if !errors.Is(dead[0].Err, context.DeadlineExceeded) || !errors.Is(dead[0].Err, errTransient) {
+			t.Fatalf("got err %v, want the last error joined with %v", dead[0].Err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(f.start); elapsed != 25*ms {
+			t.Fatalf("gave up after %v, want 25ms", elapsed)
+		}
+	})
+}
+
+func TestRetryPanic(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		fn := func(ctx context.Context, v int) (int, error) { return panicOn3(v), nil }
+		_, dead := retryAll(Retry(ctx, Generate(ctx, 3), fn, RetryPolicy{MaxAttempts: 2}))
+		var perr *PanicError
+		if len(dead) != 1 || !errors.As(dead[0].Err, &perr) || dead[0].Attempts != 2 {
+			t.Fatalf("got dead letters %+v, want one *PanicError after 2 attempts", dead)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * ms, MaxDelay: 50 * ms, Multiplier: 3}
+	for attempt, want := range []time.Duration{10 * ms, 30 * ms, 50 * ms, 50 * ms} {
+		if got := p.Backoff(attempt + 1); got != want {
+			t.Fatalf("attempt %d: got %v, want %v", attempt+1, got, want)
+		}
+	}
+	for range 100 {
+		p.Jitter = FullJitter
+		if d := p.Backoff(2); d < 0 || d >= 30*ms {
+			t.Fatalf("full jitter: got %v, want [0, 30ms)", d)
+		}
+		p.Jitter = EqualJitter
+		if d := p.Backoff(2); d < 15*ms || d >= 30*ms {
+			t.Fatalf("equal jitter: got %v, want [15ms, 30ms)", d)
+		}
+	}
+	// 不设 MaxDelay 时退避在 time.Duration 的最大值处饱和，而不是溢出为负数
+	p = RetryPolicy{BaseDelay: time.Second}
+	for _, attempt := range []int{35, 64, 1000} {
+		if got := p.Backoff(attempt); got != math.MaxInt64 {
+			t.Fatalf("attempt %d: got %v, want %v", attempt, got, time.Duration(math.MaxInt64))
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+! 包 seq 提供基于 iter.Seq 的同步流水线阶段
+- 与 pipeline 包的通道阶段一一对应，但不启动例程，也不需要 ctx：每一项在消费者的 range 循环中按需计算
+- 适合数据量小或每项开销很低、例程与通道的调度开销得不偿失的场景
+- 消费者 break 后上游不再产出任何值：seq.Take(seq.Map(seq.Count(10), sq), 5)
+*/
+package seq
+
+import "iter"
+
+// Count 从 start 开始无限产出递增的整数
+func Count(start int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for n := start; yield(n); n++ {
+		}
+	}
+}
+
+// Values 依次产出 values
+func Values[T any](values ...T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range values {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Map 对 s 中的每个值调用 fn
+func Map[T, R any](s iter.Seq[T], fn func(T) R) iter.Seq[R] {
+	return func(yield func(R) bool) {
+		for v := range s {
+			if !yield(fn(v)) {
+				return
+			}
+		}
+	}
+}
+
+// Filter 只产出 keep 返回 true 的值
+func Filter[T any](s iter.Seq[T], keep func(T) bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range s {
+			if keep(v) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Take 最多产出 s 的前 n 个值，取够后立即停止迭代 s
+func Take[T any](s iter.Seq[T], n int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for v := range s {
+			if !yield(v) {
+				return
+			}
+			if i++; i >= n {
+				return
+			}
+		}
+	}
+}
+
+// Map2 对 s 中的每对值调用 fn
+func Map2[K, V, R any](s iter.Seq2[K, V], fn func(K, V) R) iter.Seq2[K, R] {
+	return func(yield func(K, R) bool) {
+		for k, v := range s {
+			if !yield(k, fn(k, v)) {
+				return
+			}
+		}
+	}
+}
+
+// Enumerate 为 s 中的值从 0 开始编号，类似 pipeline.Sequence
+func Enumerate[T any](s iter.Seq[T]) iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := 0
+		for v := range s {
+			if !yield(i, v) {
+				return
+			}
+			i++
+		}
+	}
+}
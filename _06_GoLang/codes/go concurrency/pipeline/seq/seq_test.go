@@ -0,0 +1,57 @@
+package seq
+
+import (
+	"slices"
+	"testing"
+)
+
+func sq(n int) int { return n * n }
+
+func TestMapFilterTake(t *testing.T) {
+	odd := func(n int) bool { return n%2 == 1 }
+	got := slices.Collect(Take(Map(Filter(Count(0), odd), sq), 4))
+	if want := []int{1, 9, 25, 49}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTakeStopsUpstream(t *testing.T) {
+	calls := 0
+	counted := Map(Count(0), func(n int) int {
+		calls++
+		return n
+	})
+	if got := slices.Collect(Take(counted, 3)); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Fatalf("got %v, want [0 1 2]", got)
+	}
+	// 取够后不再多计算一项
+	if calls != 3 {
+		t.Fatalf("fn called %d times, want 3", calls)
+	}
+	if got := slices.Collect(Take(counted, 0)); got != nil {
+		t.Fatalf("got %v, want nothing", got)
+	}
+}
+
+func TestBreak(t *testing.T) {
+	var got []int
+	for v := range Map(Values(1, 2, 3, 4), sq) {
+		if v > 5 {
+			break
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{1, 4}) {
+		t.Fatalf("got %v, want [1 4]", got)
+	}
+}
+
+func TestEnumerateMap2(t *testing.T) {
+	var got []int
+	for i, v := range Map2(Enumerate(Values(5, 6, 7)), func(i, v int) int { return i * v }) {
+		got = append(got, i, v)
+	}
+	if want := []int{0, 0, 1, 6, 2, 14}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
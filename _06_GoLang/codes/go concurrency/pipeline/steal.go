@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+// StealDepth 是 FanOutStealing 中每个 worker 本地队列的平均容量，
+// 所有本地队列中的项数达到 n*StealDepth 后分发例程停止读取 in
+const StealDepth = 64
+
+// deque 是一个 worker 的本地队列：所有者从队首取出 (FIFO)，窃取者从队尾取走一半
+type deque[T any] struct {
+	mu    sync.Mutex
+	items []T
+	wake  chan struct{} // 容量为 1，有新项时通知所有者
+}
+
+func (d *deque[T]) push(vs ...T) {
+	d.mu.Lock()
+	d.items = append(d.items, vs...)
+	d.mu.Unlock()
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *deque[T]) pop() (v T, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.items) == 0 {
+		return v, false
+	}
+	v = d.items[0]
+	var zero T
+	d.items[0] = zero
+	d.items = d.items[1:]
+	return v, true
+}
+
+// stealHalf 取走队尾的一半 (向上取整)，所有者最后才会处理这些项
+func (d *deque[T]) stealHalf() []T {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	k := (len(d.items) + 1) / 2
+	if k == 0 {
+		return nil
+	}
+	rest := len(d.items) - k
+	stolen := slices.Clone(d.items[rest:])
+	clear(d.items[rest:])
+	d.items = d.items[:rest]
+	return stolen
+}
+
+// stealer 是 FanOutStealing 的调度状态
+type stealer[T any] struct {
+	ctx     context.Context
+	deques  []deque[T]
+	slots   chan struct{} // 本地队列中的项数 (含已窃取待处理的项)
+	drained chan struct{} // in 已耗尽，不再有新项
+}
+
+// dispatch 将 in 中的项轮流放入各 worker 的本地队列
+func (s *stealer[T]) dispatch(in <-chan T) {
+	defer close(s.drained)
+	for i := 0; ; i = (i + 1) % len(s.deques) {
+		var v T
+		select {
+		case <-s.ctx.Done():
+			return
+		case x, ok := <-in:
+			if !ok {
+				return
+			}
+			v = x
+		}
+		if Send(s.ctx, s.slots, struct{}{}) != nil {
+			return
+		}
+		s.deques[i].push(v)
+	}
+}
+
+// take 先从 worker i 自己的队列取，为空时从随机选择的起点依次尝试窃取其他 worker 的一半
+func (s *stealer[T]) take(i int) (v T, ok bool) {
+	if v, ok = s.deques[i].pop(); ok {
+		return v, true
+	}
+	n := len(s.deques)
+	start := rand.IntN(n)
+	for k := range n {
+		j := (start + k) % n
+		if j == i {
+			continue
+		}
+		if stolen := s.deques[j].stealHalf(); len(stolen) > 0 {
+			s.deques[i].push(stolen[1:]...)
+			return stolen[0], true
+		}
+	}
+	return v, false
+}
+
+// next 返回 worker i 的下一项；in 耗尽且无项可取或 ctx 结束时返回 false
+func (s *stealer[T]) next(i int) (v T, ok bool) {
+	for {
+		if v, ok = s.take(i); ok {
+			<-s.slots
+			return v, true
+		}
+		select {
+		case <-s.ctx.Done():
+			return v, false
+		case <-s.deques[i].wake:
+		case <-s.drained:
+			// 不再有新项，此后只能从其他 worker 的积压中窃取
+			if v, ok = s.take(i); ok {
+				<-s.slots
+				return v, true
+			}
+			return v, false
+		}
+	}
+}
+
+// FanOutStealing 与 FanOut 参数相同 (另接受 opts)，但 worker 不再竞争同一个 in 通道：
+// 分发例程将 in 中的项轮流放入每个 worker 的本地队列，worker 从自己的队列取项，
+// 本地队列为空时从随机选择的其他 worker 的队尾窃取一半。
+// 每个 work 例程的输入通道由其本地队列供给，处理慢的 worker 积压的项会被空闲的 worker 取走。
+// opts 用于各 worker 取项例程的日志，项数为该 worker 取得 (含窃取) 与交给 work 的项数。
+// ! 每项多经过一次本地队列与一次通道传递，核数较少或每项的处理很轻时反而比 FanOut 慢 (见 prime 包的 BenchmarkFanOut)
+func FanOutStealing[T, R any](ctx context.Context, n int, in <-chan T, work func(ctx context.Context, in <-chan T) <-chan R, opts ...Option) ([]<-chan R, error) {
+	if err := checkWorkers(ctx, n); err != nil {
+		return nil, err
+	}
+	s := &stealer[T]{
+		ctx:     ctx,
+		deques:  make([]deque[T], n),
+		slots:   make(chan struct{}, n*StealDepth),
+		drained: make(chan struct{}),
+	}
+	for i := range s.deques {
+		s.deques[i].wake = make(chan struct{}, 1)
+	}
+	c := newConfig(opts)
+	go s.dispatch(in)
+	outs := make([]<-chan R, n)
+	for i := range outs {
+		local := make(chan T)
+		go func() {
+			defer close(local)
+			l := c.newStageLog("fan_out_stealing", i)
+			defer l.lifecycle(ctx)()
+			for {
+				v, ok := s.next(i)
+				if !ok {
+					return
+				}
+				l.received()
+				if Send(ctx, local, v) != nil {
+					return
+				}
+				l.sent()
+			}
+		}()
+		outs[i] = work(ctx, local)
+	}
+	return outs, nil
+}
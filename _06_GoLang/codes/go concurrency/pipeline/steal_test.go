@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestFanOutStealing(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		outs, err := FanOutStealing(ctx, 3, Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8), square)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(FanIn(ctx, outs...))
+		slices.Sort(got)
+		want := []int{1, 4, 9, 16, 25, 36, 49, 64}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if _, err := FanOutStealing(ctx, 0, Generate[int](ctx), square); err == nil {
+			t.Fatal("expected error for n < 1")
+		}
+	})
+}
+
+func TestFanOutStealingSteals(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		const items = 100
+		worker := 0
+		var processed [2]int // 各 worker 只写自己的计数，读取在所有输出关闭之后
+		work := func(ctx context.Context, in <-chan int) <-chan int {
+			id := worker
+			worker++
+			return Map(ctx, in, func(v int) int {
+				if id == 0 {
+					time.Sleep(time.Second) // 慢 worker
+				}
+				processed[id]++
+				return v
+			})
+		}
+		in := make(chan int)
+		go func() {
+			defer close(in)
+			for i := range items {
+				in <- i
+			}
+		}()
+		start := time.Now()
+		outs, err := FanOutStealing(ctx, 2, in, work)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(collect(FanIn(ctx, outs...))); got != items {
+			t.Fatalf("got %d items, want %d", got, items)
+		}
+		// 轮流分发时慢 worker 分到一半的项，没有窃取需要 50s
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("took %v, slow worker processed %d items", elapsed, processed[0])
+		}
+	})
+}
+
+func TestFanOutStealingCancel(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int)
+		go func() {
+			for i := 0; ; i++ {
+				if Send(ctx, in, i) != nil {
+					return
+				}
+			}
+		}()
+		outs, err := FanOutStealing(ctx, 4, in, square)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := FanIn(ctx, outs...)
+		<-out
+		<-out
+		cancel()
+		for range out {
+		}
+		synctest.Wait() // 所有例程已退出，否则 synctest.Test 报告泄漏
+	})
+}
@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrRestartIntensity 表示监督者在时间窗口内的重启次数超出上限而放弃
+var ErrRestartIntensity = errors.New("pipeline: restart intensity exceeded")
+
+// Strategy 是监督者在子例程退出后的重启策略 (同 Erlang/OTP)
+type Strategy int
+
+const (
+	OneForOne  Strategy = iota // 只重启退出的子例程
+	OneForAll                  // 停止并重启所有子例程
+	RestForOne                 // 停止并重启退出的子例程及其后启动的子例程
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one_for_one"
+	case OneForAll:
+		return "one_for_all"
+	case RestForOne:
+		return "rest_for_one"
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// RestartType 决定子例程在何种退出下需要重启
+type RestartType int
+
+const (
+	Permanent RestartType = iota // 总是重启
+	Transient                    // 仅在出错或 panic 退出时重启
+	Temporary                    // 从不重启
+)
+
+// Child 是受监督的子例程；Run 应在 ctx 结束后尽快返回，其 panic 视为出错退出
+type Child struct {
+	Name    string
+	Run     func(ctx context.Context) error
+	Restart RestartType
+}
+
+// SupervisorSpec 配置监督者；MaxRestarts 为 0 时使用 3，Window 为 0 时使用 5s
+type SupervisorSpec struct {
+	Strategy    Strategy
+	MaxRestarts int           // Window 内允许的最大重启次数，超出后监督者停止所有子例程并返回错误
+	Window      time.Duration // 统计重启次数的时间窗口
+	OnRestart   func(name string, err error)
+}
+
+// Supervisor 按策略启动、监视并重启一组子例程。
+// ! Run 的签名与 Child.Run 相同，因此监督者可作为另一个监督者的子例程嵌套：
+// 子监督者放弃时以 ErrRestartIntensity 退出，由上级监督者决定是否重启整棵子树
+type Supervisor struct {
+	spec     SupervisorSpec
+	children []Child
+	conf     *config
+	log      *stageLog   // 监督者自身的启动与退出
+	logs     []*stageLog // 各子例程的启动 (含重启)、panic 与退出，带有属性 child
+}
+
+// NewSupervisor 返回监督 children 的监督者，children 按顺序启动
+func NewSupervisor(spec SupervisorSpec, children ...Child) *Supervisor {
+	return NewSupervisorWith(spec, children)
+}
+
+// NewSupervisorWith 同 NewSupervisor，children 以切片传入以便接受 opts；
+// 监督者使用 WithName、WithLogger、WithWorkerID 与 WithPanicHandler (子例程的 panic)
+func NewSupervisorWith(spec SupervisorSpec, children []Child, opts ...Option) *Supervisor {
+	if spec.MaxRestarts == 0 {
+		spec.MaxRestarts = 3
+	}
+	if spec.Window == 0 {
+		spec.Window = 5 * time.Second
+	}
+	c := newConfig(opts)
+	s := &Supervisor{spec: spec, children: children, conf: c, log: c.newStageLog("supervisor", c.worker)}
+	s.logs = make([]*stageLog, len(children))
+	for i, child := range children {
+		if s.logs[i] = c.newStageLog("supervisor", i); s.logs[i] != nil {
+			s.logs[i].attrs = []slog.Attr{slog.String("child", child.Name)}
+		}
+	}
+	return s
+}
+
+// AsChild 将监督者包装为可被上级监督者管理的子例程
+func (s *Supervisor) AsChild(name string, restart RestartType) Child {
+	return Child{Name: name, Run: s.Run, Restart: restart}
+}
+
+type childExit struct {
+	i, gen int
+	err    error
+}
+
+type childState struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	gen     int
+	running bool
+}
+
+// Run 启动所有子例程并监督它们，直到：
+// ctx 结束 (返回 ctx.Err())、所有子例程正常退出且无需重启 (返回 nil)，
+// 或重启次数超出上限 (返回包装了 ErrRestartIntensity 与最后一个错误的错误)。
+// Run 返回前会停止并等待所有子例程
+func (s *Supervisor) Run(ctx context.Context) (err error) {
+	s.log.start(0)
+	defer func() { s.log.exit(err) }()
+	exits := make(chan childExit)
+	stop := make(chan struct{})
+	defer close(stop)
+	states := make([]childState, len(s.children))
+
+	start := func(i int) {
+		st := &states[i]
+		var cctx context.Context
+		cctx, st.cancel = context.WithCancel(ctx)
+		st.done = make(chan struct{})
+		st.gen++
+		st.running = true
+		gen, done, run, l := st.gen, st.done, s.children[i].Run, s.logs[i]
+		l.start(gen - 1)
+		go func() {
+			var err error
+			if perr := protect(func() { err = run(cctx) }); perr != nil {
+				if s.conf.onPanic != nil {
+					s.conf.onPanic(perr)
+				}
+				l.panicked(perr)
+				err = perr
+			}
+			l.exit(err)
+			close(done)
+			select {
+			case exits <- childExit{i, gen, err}:
+			case <-stop:
+			}
+		}()
+	}
+	terminate := func(i int) {
+		st := &states[i]
+		if st.running {
+			st.cancel()
+			<-st.done
+			st.running = false
+		}
+	}
+	terminateAll := func() {
+		for i := len(states) - 1; i >= 0; i-- {
+			terminate(i)
+		}
+	}
+	defer terminateAll()
+
+	for i := range s.children {
+		start(i)
+	}
+	var restarts []time.Time
+	for {
+		var e childExit
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e = <-exits:
+		}
+		st := &states[e.i]
+		if e.gen != st.gen || !st.running {
+			continue // 已被监督者停止的旧例程
+		}
+		st.running = false
+		st.cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c := s.children[e.i]
+		if c.Restart == Temporary || c.Restart == Transient && e.err == nil {
+			if !s.anyRunning(states) {
+				return nil
+			}
+			continue
+		}
+
+		now := time.Now()
+		restarts = append(restarts, now)
+		for len(restarts) > 0 && now.Sub(restarts[0]) >= s.spec.Window {
+			restarts = restarts[1:]
+		}
+		if len(restarts) > s.spec.MaxRestarts {
+			return fmt.Errorf("%w: child %q: %w", ErrRestartIntensity, c.Name, errOrExit(e.err))
+		}
+		if s.spec.OnRestart != nil {
+			s.spec.OnRestart(c.Name, e.err)
+		}
+
+		// 按策略确定需要重启的子例程，逆序停止后顺序启动
+		first, last := e.i, e.i
+		switch s.spec.Strategy {
+		case OneForAll:
+			first, last = 0, len(states)-1
+		case RestForOne:
+			last = len(states) - 1
+		}
+		for i := last; i >= first; i-- {
+			terminate(i)
+		}
+		for i := first; i <= last; i++ {
+			if i == e.i || s.children[i].Restart != Temporary {
+				start(i)
+			}
+		}
+	}
+}
+
+func (s *Supervisor) anyRunning(states []childState) bool {
+	for _, st := range states {
+		if st.running {
+			return true
+		}
+	}
+	return false
+}
+
+// errOrExit 为正常退出的 Permanent 子例程提供错误描述
+func errOrExit(err error) error {
+	if err == nil {
+		return errors.New("exited")
+	}
+	return err
+}
+
+// FanOutSupervised 在 g 中以一个 OneForOne 监督者运行 n 个 worker 共同消费 in，返回各 worker 的输出通道。
+// fn 出错或 panic 时丢弃当前项并重启该 worker (输出通道不变)，重启由 spec.OnRestart 报告；
+// 超出重启上限时以 ErrRestartIntensity 使 g 失败。in 关闭后各 worker 正常退出并关闭其输出。
+// opts 交给 NewSupervisorWith，阶段名默认为 fan_out_supervised，worker 的启动、重启与退出记录为其子例程的事件
+func FanOutSupervised[T, R any](g *Group, spec SupervisorSpec, n int, in <-chan T, fn Stage[T, R], opts ...Option) ([]<-chan R, error) {
+	if err := checkWorkers(g.ctx, n); err != nil {
+		return nil, err
+	}
+	spec.Strategy = OneForOne
+	chans := make([]chan R, n)
+	outs := make([]<-chan R, n)
+	children := make([]Child, n)
+	var sup *Supervisor
+	for i := range children {
+		out := make(chan R)
+		chans[i], outs[i] = out, out
+		children[i] = Child{
+			Name:    fmt.Sprintf("worker-%d", i),
+			Restart: Transient,
+			Run: func(ctx context.Context) error {
+				l := sup.logs[i] // 项数在重启之间累计
+				for {
+					select {
+					case <-ctx.Done():
+						return nil
+					case v, ok := <-in:
+						if !ok {
+							return nil
+						}
+						l.received()
+						r, err := fn(ctx, v)
+						if err != nil {
+							return err
+						}
+						if Send(ctx, out, r) != nil {
+							return nil
+						}
+						l.sent()
+					}
+				}
+			},
+		}
+	}
+	sup = NewSupervisorWith(spec, children, append([]Option{WithName("fan_out_supervised")}, opts...)...)
+	g.Go(func() error {
+		defer func() {
+			for _, c := range chans {
+				close(c)
+			}
+		}()
+		if err := sup.Run(g.ctx); errors.Is(err, ErrRestartIntensity) {
+			return err
+		}
+		return nil
+	})
+	return outs, nil
+}
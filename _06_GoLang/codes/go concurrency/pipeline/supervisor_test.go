@@ -0,0 +1,227 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+// trace 记录各子例程的启动次数
+type trace struct {
+	mu     sync.Mutex
+	starts map[string]int
+}
+
+func (tr *trace) started(name string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.starts == nil {
+		tr.starts = make(map[string]int)
+	}
+	tr.starts[name]++
+}
+
+// child 返回受 tr 记录的子例程：前 crashes 次运行在 crashAfter 后以 errBoom 退出，之后一直运行到 ctx 结束
+func (tr *trace) child(name string, crashes int, crashAfter time.Duration) Child {
+	var mu sync.Mutex
+	return Child{Name: name, Run: func(ctx context.Context) error {
+		tr.started(name)
+		mu.Lock()
+		crash := crashes > 0
+		crashes--
+		mu.Unlock()
+		if crash {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(crashAfter):
+				return errBoom
+			}
+		}
+		<-ctx.Done()
+		return nil
+	}}
+}
+
+func TestSupervisorStrategies(t *testing.T) {
+	tests := []struct {
+		strategy Strategy
+		want     map[string]int
+	}{
+		{OneForOne, map[string]int{"a": 1, "b": 2, "c": 1}},
+		{OneForAll, map[string]int{"a": 2, "b": 2, "c": 2}},
+		{RestForOne, map[string]int{"a": 1, "b": 2, "c": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			synctest.Test(t, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 100*ms)
+				defer cancel()
+				var tr trace
+				sup := NewSupervisor(SupervisorSpec{Strategy: tt.strategy},
+					tr.child("a", 0, 0), tr.child("b", 1, 10*ms), tr.child("c", 0, 0))
+				if err := sup.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("got err %v, want %v", err, context.DeadlineExceeded)
+				}
+				if !maps.Equal(tr.starts, tt.want) {
+					t.Fatalf("got starts %v, want %v", tr.starts, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestSupervisorIntensity(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var tr trace
+		var restarted []error
+		sup := NewSupervisor(SupervisorSpec{
+			MaxRestarts: 2,
+			Window:      50 * ms,
+			OnRestart:   func(name string, err error) { restarted = append(restarted, err) },
+		}, tr.child("a", 100, 10*ms), tr.child("b", 0, 0))
+		start := time.Now()
+		err := sup.Run(context.Background())
+		if !errors.Is(err, ErrRestartIntensity) || !errors.Is(err, errBoom) {
+			t.Fatalf("got err %v, want %v wrapping %v", err, ErrRestartIntensity, errBoom)
+		}
+		// 第 3 次崩溃发生在 30ms，窗口内重启次数超出 2
+		if elapsed := time.Since(start); elapsed != 30*ms {
+			t.Fatalf("gave up after %v, want 30ms", elapsed)
+		}
+		if len(restarted) != 2 {
+			t.Fatalf("got %d restarts, want 2", len(restarted))
+		}
+	})
+}
+
+func TestSupervisorIntensityWindow(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*ms)
+		defer cancel()
+		var tr trace
+		// 每 30ms 崩溃一次，25ms 的窗口内最多只有一次重启
+		sup := NewSupervisor(SupervisorSpec{MaxRestarts: 1, Window: 25 * ms}, tr.child("a", 5, 30*ms))
+		if err := sup.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got err %v, want %v", err, context.DeadlineExceeded)
+		}
+		if n := tr.starts["a"]; n != 6 {
+			t.Fatalf("got %d starts, want 6", n)
+		}
+	})
+}
+
+func TestSupervisorTransientAndTemporary(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var runs atomic.Int64
+		sup := NewSupervisor(SupervisorSpec{},
+			Child{Name: "once", Restart: Temporary, Run: func(context.Context) error {
+				runs.Add(1)
+				return errBoom
+			}},
+			Child{Name: "done", Restart: Transient, Run: func(context.Context) error {
+				runs.Add(1)
+				return nil
+			}})
+		if err := sup.Run(context.Background()); err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		}
+		if n := runs.Load(); n != 2 {
+			t.Fatalf("got %d runs, want 2", n)
+		}
+	})
+}
+
+func TestSupervisorPanicAndNesting(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*ms)
+		defer cancel()
+		var tr trace
+		panics := 0
+		inner := NewSupervisor(SupervisorSpec{
+			MaxRestarts: 1,
+			Window:      time.Second,
+			OnRestart: func(name string, err error) {
+				if perr := (*PanicError)(nil); !errors.As(err, &perr) {
+					t.Errorf("got err %v, want a *PanicError", err)
+				}
+			},
+		},
+			Child{Name: "panicky", Run: func(ctx context.Context) error {
+				time.Sleep(10 * ms)
+				panics++
+				panic(errBoom)
+			}})
+		var restarted []string
+		outer := NewSupervisor(SupervisorSpec{
+			OnRestart: func(name string, err error) { restarted = append(restarted, name) },
+		}, inner.AsChild("inner", Transient), tr.child("sibling", 0, 0))
+		err := outer.Run(ctx)
+		if !errors.Is(err, ErrRestartIntensity) {
+			t.Fatalf("got err %v, want %v", err, ErrRestartIntensity)
+		}
+		// 内层每次放弃前运行 panicky 2 次；外层重启 inner 3 次后放弃
+		if panics != 8 {
+			t.Fatalf("got %d panics, want 8", panics)
+		}
+		if !slices.Equal(restarted, []string{"inner", "inner", "inner"}) {
+			t.Fatalf("outer restarted %v, want inner x3", restarted)
+		}
+	})
+}
+
+func TestFanOutSupervised(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		var crashed []string
+		outs, err := FanOutSupervised(g, SupervisorSpec{
+			MaxRestarts: 10,
+			OnRestart:   func(name string, err error) { crashed = append(crashed, err.Error()) },
+		}, 3, Generate(ctx, 1, 2, 3, 4, 5, 6, 7, 8), func(ctx context.Context, n int) (int, error) {
+			if n%3 == 0 {
+				return 0, errBoom
+			}
+			return n * n, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(FanIn(ctx, outs...))
+		if err := g.Wait(); err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		}
+		slices.Sort(got)
+		if want := []int{1, 4, 16, 25, 49, 64}; !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if len(crashed) != 2 {
+			t.Fatalf("got %d crashes reported, want 2", len(crashed))
+		}
+	})
+}
+
+func TestFanOutSupervisedGivesUp(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		outs, err := FanOutSupervised(g, SupervisorSpec{MaxRestarts: 1}, 2, Repeat(ctx, counter(0)),
+			func(ctx context.Context, n int) (int, error) {
+				if n%2 == 1 {
+					return 0, errOdd
+				}
+				return n * n, nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		collect(FanIn(ctx, outs...))
+		if err := g.Wait(); !errors.Is(err, ErrRestartIntensity) || !errors.Is(err, errOdd) {
+			t.Fatalf("got err %v, want %v wrapping %v", err, ErrRestartIntensity, errOdd)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// emit 发送一个批次并通过 pr 与 l 报告，返回阶段是否可以继续；
+// ctx 已结束时改为非阻塞发送：下游仍在接收则交付未满的批次，否则丢弃，保证不会阻塞或泄漏
+func emit[T any](ctx context.Context, out chan<- []T, batch []T, pr *probe, l *stageLog) bool {
+	if len(batch) == 0 {
+		return ctx.Err() == nil
+	}
+	sending := pr.now()
+	if Send(ctx, out, batch) == nil {
+		pr.sent(sending)
+		l.sent()
+		return true
+	}
+	select {
+	case out <- batch:
+		pr.sent(sending)
+		l.sent()
+	default:
+	}
+	return false
+}
+
+// Batch 将 in 中的值按批输出：凑满 size 个，或距批次第一个值已过 timeout，以先到者为准。
+// size <= 0 时不限数量，timeout <= 0 时不限时间；in 关闭或 ctx 结束时输出未满的批次。
+// 度量中收到的项数按值计、发出的项数按批次计，窗口阶段同样如此
+func Batch[T any](ctx context.Context, in <-chan T, size int, timeout time.Duration, opts ...Option) <-chan []T {
+	c := newConfig(opts)
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("batch")
+		l := c.newStageLog("batch", c.worker)
+		defer l.lifecycle(ctx)()
+		waiting := pr.now()
+		var batch []T
+		timer := time.NewTimer(timeout)
+		timer.Stop()
+		defer timer.Stop()
+		var expired <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				emit(ctx, out, batch, pr, l)
+				return
+			case v, ok := <-in:
+				if !ok {
+					emit(ctx, out, batch, pr, l)
+					return
+				}
+				probeReceived(pr, waiting, in)
+				l.received()
+				waiting = pr.now()
+				if len(batch) == 0 && timeout > 0 {
+					timer.Reset(timeout)
+					expired = timer.C
+				}
+				batch = append(batch, v)
+				if size <= 0 || len(batch) < size {
+					continue
+				}
+			case <-expired:
+			}
+			timer.Stop()
+			expired = nil
+			if !emit(ctx, out, batch, pr, l) {
+				return
+			}
+			batch = nil
+		}
+	}()
+	return out
+}
+
+// BatchCount 每凑满 n 个值输出一批
+func BatchCount[T any](ctx context.Context, in <-chan T, n int, opts ...Option) <-chan []T {
+	return Batch(ctx, in, n, 0, opts...)
+}
+
+// BatchTime 输出距批次第一个值 d 时间内到达的所有值
+func BatchTime[T any](ctx context.Context, in <-chan T, d time.Duration, opts ...Option) <-chan []T {
+	return Batch(ctx, in, 0, d, opts...)
+}
+
+// TumblingWindow 将时间划分为从阶段启动起、长度为 size 的首尾相接的窗口，
+// 每个窗口结束时输出其中到达的值 (空窗口不输出)；size 不是正数时返回错误
+func TumblingWindow[T any](ctx context.Context, in <-chan T, size time.Duration, opts ...Option) (<-chan []T, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("pipeline: window size %v is not positive", size)
+	}
+	c := newConfig(opts)
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("tumbling_window")
+		l := c.newStageLog("tumbling_window", c.worker)
+		defer l.lifecycle(ctx)()
+		waiting := pr.now()
+		ticker := time.NewTicker(size)
+		defer ticker.Stop()
+		var window []T
+		for {
+			select {
+			case <-ctx.Done():
+				emit(ctx, out, window, pr, l)
+				return
+			case v, ok := <-in:
+				if !ok {
+					emit(ctx, out, window, pr, l)
+					return
+				}
+				probeReceived(pr, waiting, in)
+				l.received()
+				waiting = pr.now()
+				window = append(window, v)
+			case <-ticker.C:
+				if !emit(ctx, out, window, pr, l) {
+					return
+				}
+				window = nil
+			}
+		}
+	}()
+	return out, nil
+}
+
+// SlidingWindow 每隔 slide 输出最近 size 时间内到达的值，相邻窗口在 slide < size 时相互重叠
+// (空窗口不输出)；in 关闭时输出截至此刻的最后一个窗口。
+// slide 不是正数或大于 size (窗口之间的值将不属于任何窗口) 时返回错误
+func SlidingWindow[T any](ctx context.Context, in <-chan T, size, slide time.Duration, opts ...Option) (<-chan []T, error) {
+	if slide <= 0 || slide > size {
+		return nil, fmt.Errorf("pipeline: window slide %v must be positive and at most the size %v", slide, size)
+	}
+	type stamped struct {
+		at time.Time
+		v  T
+	}
+	c := newConfig(opts)
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("sliding_window")
+		l := c.newStageLog("sliding_window", c.worker)
+		defer l.lifecycle(ctx)()
+		waiting := pr.now()
+		ticker := time.NewTicker(slide)
+		defer ticker.Stop()
+		var items []stamped
+		// values 丢弃已滑出窗口 (now-size, now] 的值，返回窗口内剩余的值
+		values := func(now time.Time) []T {
+			items = slices.DeleteFunc(items, func(s stamped) bool { return !s.at.After(now.Add(-size)) })
+			vs := make([]T, len(items))
+			for i, s := range items {
+				vs[i] = s.v
+			}
+			return vs
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				emit(ctx, out, values(time.Now()), pr, l)
+				return
+			case v, ok := <-in:
+				if !ok {
+					emit(ctx, out, values(time.Now()), pr, l)
+					return
+				}
+				probeReceived(pr, waiting, in)
+				l.received()
+				waiting = pr.now()
+				items = append(items, stamped{time.Now(), v})
+			case now := <-ticker.C:
+				if !emit(ctx, out, values(now), pr, l) {
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// SessionWindow 将间隔不超过 gap 的连续值归为一个会话，超过 gap 没有新值时输出该会话
+func SessionWindow[T any](ctx context.Context, in <-chan T, gap time.Duration, opts ...Option) <-chan []T {
+	c := newConfig(opts)
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		pr := c.newProbe("session_window")
+		l := c.newStageLog("session_window", c.worker)
+		defer l.lifecycle(ctx)()
+		waiting := pr.now()
+		var session []T
+		timer := time.NewTimer(gap)
+		timer.Stop()
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				emit(ctx, out, session, pr, l)
+				return
+			case v, ok := <-in:
+				if !ok {
+					emit(ctx, out, session, pr, l)
+					return
+				}
+				probeReceived(pr, waiting, in)
+				l.received()
+				waiting = pr.now()
+				session = append(session, v)
+				timer.Reset(gap)
+			case <-timer.C:
+				if !emit(ctx, out, session, pr, l) {
+					return
+				}
+				session = nil
+			}
+		}
+	}()
+	return out
+}
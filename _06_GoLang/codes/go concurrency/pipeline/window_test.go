@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+// emitAt 在相对启动时刻的 at[i] 发送 i，发送完毕后关闭通道
+func emitAt(ctx context.Context, at ...time.Duration) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		start := time.Now()
+		for i, d := range at {
+			time.Sleep(d - time.Since(start))
+			if Send(ctx, out, i) != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func checkBatches(t *testing.T, got, want [][]int) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestBatchCount(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		got := collect(BatchCount(ctx, Generate(ctx, 0, 1, 2, 3, 4, 5, 6), 3))
+		checkBatches(t, got, [][]int{{0, 1, 2}, {3, 4, 5}, {6}})
+	})
+}
+
+func TestBatchTime(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// 批次计时从第一个值开始：[0,10) 与 [25,35)
+		in := emitAt(ctx, 0, 3*ms, 9*ms, 25*ms, 30*ms, 40*ms)
+		got := collect(BatchTime(ctx, in, 10*ms))
+		checkBatches(t, got, [][]int{{0, 1, 2}, {3, 4}, {5}})
+	})
+}
+
+func TestBatchCountOrTime(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := emitAt(ctx, 0, 1*ms, 2*ms, 3*ms, 20*ms)
+		got := collect(Batch(ctx, in, 2, 10*ms))
+		checkBatches(t, got, [][]int{{0, 1}, {2, 3}, {4}})
+
+		in = emitAt(ctx, 0, 1*ms, 15*ms)
+		got = collect(Batch(ctx, in, 5, 10*ms))
+		checkBatches(t, got, [][]int{{0, 1}, {2}})
+	})
+}
+
+func TestBatchFlushOnCancel(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int)
+		out := BatchCount(ctx, in, 10)
+		in <- 1
+		in <- 2
+		// 下游正在接收时，取消会交付未满的批次而不是丢弃
+		result := make(chan [][]int)
+		go func() { result <- collect(out) }()
+		synctest.Wait()
+		cancel()
+		checkBatches(t, <-result, [][]int{{1, 2}})
+	})
+}
+
+func TestTumblingWindow(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// 窗口 [0,10) [10,20) [20,30) [30,40)，其中 [20,30) 为空
+		in := emitAt(ctx, 1*ms, 5*ms, 12*ms, 31*ms, 35*ms)
+		out, err := TumblingWindow(ctx, in, 10*ms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkBatches(t, collect(out), [][]int{{0, 1}, {2}, {3, 4}})
+		if _, err := TumblingWindow(ctx, in, 0); err == nil {
+			t.Fatal("size 0: want error")
+		}
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// 长度 10ms、每 5ms 滑动的窗口：(-5,5] (0,10] (5,15]，in 在 17ms 关闭时输出 (7,17]
+		in := emitAt(ctx, 1*ms, 6*ms, 11*ms, 17*ms)
+		out, err := SlidingWindow(ctx, in, 10*ms, 5*ms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkBatches(t, collect(out), [][]int{{0}, {0, 1}, {1, 2}, {2, 3}})
+		for _, slide := range []time.Duration{0, -ms, 11 * ms} {
+			if _, err := SlidingWindow(ctx, in, 10*ms, slide); err == nil {
+				t.Fatalf("slide %v: want error", slide)
+			}
+		}
+	})
+}
+
+func TestSessionWindow(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := emitAt(ctx, 0, 3*ms, 6*ms, 20*ms, 22*ms, 40*ms)
+		got := collect(SessionWindow(ctx, in, 5*ms))
+		checkBatches(t, got, [][]int{{0, 1, 2}, {3, 4}, {5}})
+	})
+}
+
+func TestWindowComposesWithMap(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sum := func(batch []int) int {
+			total := 0
+			for _, v := range batch {
+				total += v
+			}
+			return total
+		}
+		got := collect(Map(ctx, BatchCount(ctx, Map(ctx, Generate(ctx, 1, 2, 3, 4), sq), 2), sum))
+		if !reflect.DeepEqual(got, []int{5, 25}) {
+			t.Fatalf("got %v, want [5 25]", got)
+		}
+	})
+}
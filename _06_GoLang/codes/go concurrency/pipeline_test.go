@@ -1,8 +1,9 @@
 package examples
 
 import (
-	"fmt"
+	"slices"
 	"testing"
+	"testing/synctest"
 )
 
 // Pipeline
@@ -31,8 +32,15 @@ func sq(in <-chan int) <-chan int {
 
 // last stage: consumer
 func TestPipeline(t *testing.T) {
-	upstream := generate([]int{1, 2, 3, 4, 5, 66}...)
-	for n := range sq(upstream) {
-		fmt.Println(n)
-	}
+	synctest.Test(t, func(t *testing.T) {
+		upstream := generate([]int{1, 2, 3, 4, 5, 66}...)
+		var got []int
+		for n := range sq(upstream) {
+			got = append(got, n)
+		}
+		want := []int{1, 4, 9, 16, 25, 4356}
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
 }
@@ -2,8 +2,10 @@ package examples
 
 import (
 	"fmt"
+	"slices"
 	"sync"
 	"testing"
+	"testing/synctest"
 )
 
 func repeatFn(fn func(), n int) {
@@ -44,17 +46,23 @@ func processDataUseLock(wg *sync.WaitGroup, rt *[]int, data int) {
 	lock.Unlock()
 }
 func TestLockGoroutine(t *testing.T) {
-	repeatFn(func() {
-		var wg sync.WaitGroup
-		input := []int{1, 2, 3, 4, 5}
-		result := []int{}
-		for _, data := range input {
-			wg.Add(1)
-			go processDataUseLock(&wg, &result, data)
-		}
-		wg.Wait()
-		fmt.Println(result)
-	}, 10)
+	synctest.Test(t, func(t *testing.T) {
+		repeatFn(func() {
+			var wg sync.WaitGroup
+			input := []int{1, 2, 3, 4, 5}
+			result := []int{}
+			for _, data := range input {
+				wg.Add(1)
+				go processDataUseLock(&wg, &result, data)
+			}
+			wg.Wait()
+			// 加锁后不会丢失写入，但追加顺序仍不确定
+			slices.Sort(result)
+			if want := []int{2, 4, 6, 8, 10}; !slices.Equal(result, want) {
+				t.Fatalf("got %v, want %v", result, want)
+			}
+		}, 10)
+	})
 }
 
 // no share
@@ -64,15 +72,19 @@ func processDataNoShare(wg *sync.WaitGroup, rt *int, data int) {
 }
 
 func TestNoShareGoroutine(t *testing.T) {
-	repeatFn(func() {
-		var wg sync.WaitGroup
-		input := []int{1, 2, 3, 4, 5}
-		result := make([]int, len(input))
-		for i, data := range input {
-			wg.Add(1)
-			go processDataNoShare(&wg, &result[i], data)
-		}
-		wg.Wait()
-		fmt.Println(result)
-	}, 10)
+	synctest.Test(t, func(t *testing.T) {
+		repeatFn(func() {
+			var wg sync.WaitGroup
+			input := []int{1, 2, 3, 4, 5}
+			result := make([]int, len(input))
+			for i, data := range input {
+				wg.Add(1)
+				go processDataNoShare(&wg, &result[i], data)
+			}
+			wg.Wait()
+			if want := []int{2, 4, 6, 8, 10}; !slices.Equal(result, want) {
+				t.Fatalf("got %v, want %v", result, want)
+			}
+		}, 10)
+	})
 }